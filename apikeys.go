@@ -0,0 +1,76 @@
+package cfs3
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+const apiKeyPrefix = "cfs3_"
+
+// ErrInvalidAPIKey is returned for unknown, malformed or revoked API keys.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueAPIKey creates a new API key for owner, limited to project unless it is empty. The
+// plain key is returned only once; D1 keeps just its digest.
+func IssueAPIKey(owner, project string) (string, *worker.APIKey, error) {
+	if strings.TrimSpace(owner) == "" {
+		return "", nil, errors.New("API key owner is required")
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("generating API key: %w", err)
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	record := &worker.APIKey{
+		Owner:   owner,
+		Prefix:  key[:len(apiKeyPrefix)+6],
+		KeyHash: hashAPIKey(key),
+		Project: project,
+	}
+	if err := worker.CreateAPIKey(record); err != nil {
+		return "", nil, fmt.Errorf("storing API key: %w", err)
+	}
+
+	return key, record, nil
+}
+
+// ErrAPIKeyScope is returned when a key is used for a project it isn't issued for.
+var ErrAPIKeyScope = errors.New("API key is not valid for this project")
+
+// AuthorizeProject checks that key may access project.
+func AuthorizeProject(key *worker.APIKey, project string) error {
+	if key.Project != "" && key.Project != project {
+		return ErrAPIKeyScope
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves a plain API key to its active record.
+func AuthenticateAPIKey(key string) (*worker.APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	record, err := worker.FindActiveAPIKey(hashAPIKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("looking up API key: %w", err)
+	}
+	if record == nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return record, nil
+}
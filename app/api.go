@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3"
+	"github.com/Hack-Nocturne/cfs3/server"
+)
+
+// runAPI handles `cfs3 api`: the REST management API, authenticated with cfs3-issued API keys.
+func runAPI(args []string) {
+	flags := flag.NewFlagSet("api", flag.ExitOnError)
+	listen := flags.String("listen", "127.0.0.1:8788", "TCP address, or unix:/path/to/socket")
+	window := flags.Duration("window", 2*time.Second, "how long to collect writes before deploying")
+	maxFiles := flags.Int("max-files", 40, "deploy early once a batch holds this many files")
+	defaultsFile := flags.String("config", "", "optional config file with upload tuning and deployment budget")
+	flags.Parse(args)
+
+	opts := cfs3.CoalescerOptions{Window: *window, MaxFiles: *maxFiles}
+	if *defaultsFile != "" {
+		defaults, err := cfs3.NewCFS3ConfigFromFile(*defaultsFile)
+		if err != nil {
+			fmt.Println("❌ Failure loading config:", err)
+			return
+		}
+		opts.Defaults = defaults
+	}
+
+	coalescer := cfs3.NewCoalescer(opts)
+	serveUntilSignal(*listen, server.NewAPIHandler(coalescer), coalescer.Close)
+}
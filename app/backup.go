@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// runExport handles `cfs3 export <file|->`: it writes every cfs3 row in D1 as NDJSON.
+func runExport(args []string) {
+	if len(args) == 0 {
+		fmt.Println("❌ Usage: export <file|->")
+		return
+	}
+
+	var w io.Writer = os.Stdout
+	if args[0] != "-" {
+		f, err := os.Create(args[0])
+		if err != nil {
+			fmt.Println("❌ Failure creating export file:", err)
+			return
+		}
+		defer f.Close()
+		w = f
+	}
+
+	counts, err := worker.ExportTables(w)
+	if err != nil {
+		fmt.Println("❌ Export failed:", err)
+		return
+	}
+	if args[0] != "-" {
+		fmt.Println("📦 Exported to " + args[0] + ":")
+		printCounts(counts)
+	}
+}
+
+// runImportMeta handles `cfs3 import-meta <file|->`: it upserts the rows of an export into D1.
+func runImportMeta(args []string) {
+	if len(args) == 0 {
+		fmt.Println("❌ Usage: import-meta <file|->")
+		return
+	}
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Println("❌ Failure opening export file:", err)
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	counts, err := worker.ImportTables(r)
+	if err != nil {
+		fmt.Println("❌ Import failed:", err)
+		if len(counts) > 0 {
+			fmt.Println("Rows imported before the failure (safe to re-run):")
+			printCounts(counts)
+		}
+		return
+	}
+	fmt.Println("📥 Imported:")
+	printCounts(counts)
+}
+
+func printCounts(counts map[string]int) {
+	for _, table := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Printf("  %s\t%d\n", table, counts[table])
+	}
+}
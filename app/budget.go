@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3"
+)
+
+// runBudget handles `cfs3 budget [config_file]`: it shows the remaining daily deployments
+// for the project and limit named in the config.
+func runBudget(args []string) {
+	var configFile string
+	if len(args) > 0 {
+		configFile = args[0]
+	}
+
+	config, ok := loadConfig(configFile)
+	if !ok {
+		return
+	}
+
+	budget, err := cfs3.FetchDeployBudget(config.ProjectName, config.DailyDeployLimit)
+	if err != nil {
+		fmt.Println("❌ Failure fetching deployment budget:", err)
+		return
+	}
+
+	fmt.Printf("📊 %s: %d deployments in the last 24h\n", config.ProjectName, budget.Used)
+	if budget.Limit > 0 {
+		fmt.Printf("   %d/%d remaining\n", budget.Remaining, budget.Limit)
+	} else {
+		fmt.Println("   no daily limit configured")
+	}
+	if !budget.ResetsAt.IsZero() {
+		fmt.Println("   next slot frees up at " + budget.ResetsAt.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Hack-Nocturne/cfs3/utils"
+)
+
+// runCache handles `cfs3 cache [clear]`: it shows or clears the local hash cache.
+func runCache(args []string) {
+	cachePath, err := utils.HashCachePath()
+	if err != nil {
+		fmt.Println("❌ Failure locating hash cache:", err)
+		return
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "clear":
+			if err := utils.ClearHashCache(); err != nil {
+				fmt.Println("❌ Failure clearing hash cache:", err)
+				return
+			}
+			fmt.Println("🧹 Hash cache cleared: " + cachePath)
+		default:
+			fmt.Println("❌ Unknown cache command:", args[0])
+		}
+		return
+	}
+
+	entries, size, err := utils.HashCacheStats()
+	if err != nil {
+		fmt.Println("❌ Failure reading hash cache:", err)
+		return
+	}
+
+	fmt.Println("🗂️ Hash cache: " + cachePath)
+	fmt.Printf("   %d entries, %.2f KB on disk\n", entries, float64(size)/1024)
+}
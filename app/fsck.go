@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// runFsck handles `cfs3 fsck [-repair d1|deploy] [config_file]`: it compares D1 with the live
+// deployment of the config's project and optionally reconciles them.
+func runFsck(args []string) {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := flags.String("repair", "", "'d1' to make D1 match the deployment, 'deploy' to redeploy what D1 records")
+	flags.Parse(args)
+
+	if *repair != "" && *repair != "d1" && *repair != "deploy" {
+		fmt.Println("❌ Usage: fsck [-repair d1|deploy] [config_file]")
+		return
+	}
+
+	config, ok := loadConfig(flags.Arg(0))
+	if !ok {
+		return
+	}
+
+	report, err := config.Fsck()
+	if err != nil {
+		fmt.Println("❌ Failure checking project:", err)
+		return
+	}
+
+	fmt.Printf("🔎 %s: D1 compared with deployment %s\n", report.ProjectName, report.DeploymentID)
+	printPaths("missing from the deployment", report.Missing)
+	printPaths("not recorded in D1", report.Extra)
+	printPaths("hash mismatch", report.Mismatched)
+	if report.Clean() {
+		fmt.Println("✅ D1 and the deployment agree")
+		return
+	}
+
+	switch *repair {
+	case "d1":
+		if err := config.RepairD1(report); err != nil {
+			fmt.Println("❌ Failure repairing D1:", err)
+			return
+		}
+		fmt.Println("🛠️ D1 now matches the deployment")
+	case "deploy":
+		deployResp, err := config.Redeploy()
+		if err != nil {
+			fmt.Println("❌ Redeployment failed:", err)
+			return
+		}
+		fmt.Println("💫 Redeployed D1 state with ID: " + deployResp.ID)
+	}
+}
+
+func printPaths(label string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+
+	fmt.Printf("   %d %s:\n", len(paths), label)
+	for _, p := range paths {
+		fmt.Println("     - " + p)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// runImport handles `cfs3 import [-deployment id] [config_file]`: it records the files of an
+// existing deployment of the config's project in D1.
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	deploymentId := flags.String("deployment", "", "deployment to import, defaults to the latest production deployment")
+	flags.Parse(args)
+
+	config, ok := loadConfig(flags.Arg(0))
+	if !ok {
+		return
+	}
+	if config.By == "" || config.ProjectName == "" {
+		fmt.Println("❌ Config must set 'by' and 'project_name'")
+		return
+	}
+
+	result, err := config.Import(*deploymentId)
+	if err != nil {
+		fmt.Println("❌ Failure importing deployment:", err)
+		return
+	}
+
+	fmt.Printf("📥 Imported %d file(s) from deployment %s (%d already recorded)\n",
+		result.Imported, result.DeploymentID, result.Skipped)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// runKeys handles `cfs3 keys create <owner> [project] | ls | revoke <id>`.
+func runKeys(args []string) {
+	if len(args) == 0 {
+		fmt.Println("❌ Usage: keys create <owner> [project] | keys ls | keys revoke <id>")
+		return
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) < 2 {
+			fmt.Println("❌ Usage: keys create <owner> [project]")
+			return
+		}
+		project := ""
+		if len(args) > 2 {
+			project = args[2]
+		}
+		key, record, err := cfs3.IssueAPIKey(args[1], project)
+		if err != nil {
+			fmt.Println("❌ Failure creating API key:", err)
+			return
+		}
+		fmt.Printf("🔑 API key #%d for %s on %s (shown only once):\n%s\n", record.ID, record.Owner, keyScope(*record), key)
+	case "ls":
+		keys, err := worker.ListAPIKeys()
+		if err != nil {
+			fmt.Println("❌ Failure listing API keys:", err)
+			return
+		}
+		for _, k := range keys {
+			status := "active"
+			if k.RevokedAt != nil {
+				status = "revoked " + k.RevokedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("#%d\t%s…\t%s\t%s\t%s\n", k.ID, k.Prefix, k.Owner, keyScope(k), status)
+		}
+	case "revoke":
+		if len(args) < 2 {
+			fmt.Println("❌ Usage: keys revoke <id>")
+			return
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			fmt.Println("❌ Invalid key id:", args[1])
+			return
+		}
+		revoked, err := worker.RevokeAPIKey(id)
+		if err != nil {
+			fmt.Println("❌ Failure revoking API key:", err)
+			return
+		}
+		if !revoked {
+			fmt.Printf("❌ No active API key #%d\n", id)
+			return
+		}
+		fmt.Printf("🔒 API key #%d revoked\n", id)
+	default:
+		fmt.Println("❌ Unknown keys command:", args[0])
+	}
+}
+
+// keyScope describes the projects a key can access.
+func keyScope(k worker.APIKey) string {
+	if k.Project == "" {
+		return "all projects"
+	}
+	return k.Project
+}
@@ -5,16 +5,74 @@ import (
 	"os"
 
 	"github.com/Hack-Nocturne/cfs3"
-	"github.com/Hack-Nocturne/cfs3/vars"
 	_ "github.com/joho/godotenv/autoload"
 )
 
-func init() {
-	os.MkdirAll(vars.UPLOAD_BASE_DIR, 0o755)
-}
-
 func main() {
-	defer func() { os.RemoveAll(vars.UPLOAD_BASE_DIR) }()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cache":
+			runCache(os.Args[2:])
+			return
+		case "budget":
+			runBudget(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "s3":
+			runS3(os.Args[2:])
+			return
+		case "api":
+			runAPI(os.Args[2:])
+			return
+		case "keys":
+			runKeys(os.Args[2:])
+			return
+		case "pull":
+			runPull(os.Args[2:])
+			return
+		case "fsck":
+			runFsck(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "versions":
+			runVersions(os.Args[2:])
+			return
+		case "trash":
+			runTrash(os.Args[2:])
+			return
+		case "undelete":
+			runUndelete(os.Args[2:])
+			return
+		case "deployments":
+			runDeployments(os.Args[2:])
+			return
+		case "rollback":
+			runRollback(os.Args[2:])
+			return
+		case "snapshot":
+			runSnapshot(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import-meta":
+			runImportMeta(os.Args[2:])
+			return
+		case "meta-index":
+			runMetaIndex(os.Args[2:])
+			return
+		case "search":
+			runSearch(os.Args[2:])
+			return
+		case "schema":
+			runSchema(os.Args[2:])
+			return
+		}
+	}
 
 	configFile := "cfs3.config.json"
 	if len(os.Args) > 1 {
@@ -36,3 +94,18 @@ func main() {
 		fmt.Println("❌ Failure processing config:", apErr)
 	}
 }
+
+// loadConfig loads the given config file, defaulting to cfs3.config.json, and reports failures.
+func loadConfig(configFile string) (*cfs3.CFS3Config, bool) {
+	if configFile == "" {
+		configFile = "cfs3.config.json"
+	}
+
+	config, cfgErr := cfs3.NewCFS3ConfigFromFile(configFile)
+	if cfgErr != nil {
+		fmt.Println("❌ Failure loading config:", cfgErr)
+		return nil, false
+	}
+
+	return config, true
+}
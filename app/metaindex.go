@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+const metaIndexUsage = "❌ Usage: meta-index ls | meta-index add <key> | meta-index rm <key>"
+
+// runMetaIndex handles `cfs3 meta-index ls|add|rm`: it manages the metadata keys promoted to
+// indexed columns.
+func runMetaIndex(args []string) {
+	if len(args) == 0 {
+		fmt.Println(metaIndexUsage)
+		return
+	}
+
+	switch args[0] {
+	case "ls":
+		indexes, err := worker.ListMetadataIndexes()
+		if err != nil {
+			fmt.Println("❌ Failure listing promoted keys:", err)
+			return
+		}
+		for _, index := range indexes {
+			fmt.Printf("%s\t%s\n", index.Key, index.Column)
+		}
+	case "add":
+		if len(args) < 2 {
+			fmt.Println(metaIndexUsage)
+			return
+		}
+		index, err := worker.PromoteMetadataKey(args[1])
+		if err != nil {
+			fmt.Println("❌ Failure promoting key:", err)
+			return
+		}
+		fmt.Printf("⚡ %s is indexed as column %s\n", index.Key, index.Column)
+	case "rm":
+		if len(args) < 2 {
+			fmt.Println(metaIndexUsage)
+			return
+		}
+		removed, err := worker.DemoteMetadataKey(args[1])
+		if err != nil {
+			fmt.Println("❌ Failure dropping index:", err)
+			return
+		}
+		if !removed {
+			fmt.Println("❌ Key is not promoted:", args[1])
+			return
+		}
+		fmt.Println("🗑️ Dropped the index of " + args[1])
+	default:
+		fmt.Println(metaIndexUsage)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/Hack-Nocturne/cfs3"
+	"github.com/Hack-Nocturne/cfs3/vars"
+)
+
+// runPull handles `cfs3 pull [-prefix p] <project> <dir>`: it mirrors a project's objects into a local directory.
+func runPull(args []string) {
+	flags := flag.NewFlagSet("pull", flag.ExitOnError)
+	prefix := flags.String("prefix", "", "only pull objects whose path starts with this prefix")
+	concurrency := flags.Int("concurrency", vars.PULL_CONCURRENCY, "parallel downloads")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		fmt.Println("❌ Usage: pull [-prefix p] [-concurrency n] <project> <dir>")
+		return
+	}
+
+	result, err := cfs3.Pull(flags.Arg(0), flags.Arg(1), cfs3.PullOptions{Prefix: *prefix, Concurrency: *concurrency})
+	if result != nil {
+		fmt.Printf("⬇️ Pulled %d file(s) (%.2f MB), %d already up to date\n",
+			result.Downloaded, float64(result.Bytes)/(vars.KB_SIZE*vars.KB_SIZE), result.Unchanged)
+	}
+	if err != nil {
+		fmt.Println("❌ Failure pulling objects:", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// runDeployments handles `cfs3 deployments [config_file]`: it lists the recent deployments
+// recorded for the config's project.
+func runDeployments(args []string) {
+	var configFile string
+	if len(args) > 0 {
+		configFile = args[0]
+	}
+	config, ok := loadConfig(configFile)
+	if !ok {
+		return
+	}
+
+	deployments, err := worker.ListDeployments(config.ProjectName, 50)
+	if err != nil {
+		fmt.Println("❌ Failure listing deployments:", err)
+		return
+	}
+	for _, d := range deployments {
+		by := "-"
+		if d.By != nil {
+			by = *d.By
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t+%d -%d\n",
+			d.ID, d.CreatedAt.Format(time.RFC3339), d.Mode, by, len(d.AddedObjects), len(d.RemovedObjects))
+	}
+}
+
+// runRollback handles `cfs3 rollback <deployment_id> [config_file]`: it restores the config's
+// project to its state as of that deployment.
+func runRollback(args []string) {
+	if len(args) == 0 {
+		fmt.Println("❌ Usage: rollback <deployment_id> [config_file]")
+		return
+	}
+
+	var configFile string
+	if len(args) > 1 {
+		configFile = args[1]
+	}
+	config, ok := loadConfig(configFile)
+	if !ok {
+		return
+	}
+
+	result, err := config.Rollback(args[0])
+	if err != nil {
+		fmt.Println("❌ Rollback failed:", err)
+		return
+	}
+
+	fmt.Printf("⏪ %s is back at deployment %s: %d object(s) restored, %d removed\n",
+		config.ProjectName, args[0], len(result.Objects), len(result.Removed))
+	fmt.Println("🌐 Take a peek over " + result.URL)
+}
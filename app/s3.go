@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3"
+	"github.com/Hack-Nocturne/cfs3/server"
+)
+
+// runS3 handles `cfs3 s3`: an S3-compatible HTTP gateway where buckets are Pages projects.
+func runS3(args []string) {
+	flags := flag.NewFlagSet("s3", flag.ExitOnError)
+	listen := flags.String("listen", "127.0.0.1:9000", "TCP address, or unix:/path/to/socket")
+	by := flags.String("by", "s3-gateway", "uploader recorded for objects written through the gateway")
+	window := flags.Duration("window", 2*time.Second, "how long to collect writes before deploying")
+	maxFiles := flags.Int("max-files", 40, "deploy early once a batch holds this many files")
+	defaultsFile := flags.String("config", "", "optional config file with upload tuning and deployment budget")
+	flags.Parse(args)
+
+	opts := cfs3.CoalescerOptions{Window: *window, MaxFiles: *maxFiles}
+	if *defaultsFile != "" {
+		defaults, err := cfs3.NewCFS3ConfigFromFile(*defaultsFile)
+		if err != nil {
+			fmt.Println("❌ Failure loading config:", err)
+			return
+		}
+		opts.Defaults = defaults
+	}
+
+	coalescer := cfs3.NewCoalescer(opts)
+	serveUntilSignal(*listen, server.NewS3Handler(coalescer, server.S3Options{By: *by}), coalescer.Close)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+const schemaUsage = "❌ Usage: schema set <schema.json> [config_file] | schema show|check|rm [config_file]"
+
+// runSchema handles `cfs3 schema ...`: it manages the metadata schema of the config's project.
+func runSchema(args []string) {
+	if len(args) == 0 {
+		fmt.Println(schemaUsage)
+		return
+	}
+
+	rest := args[1:]
+	var schemaFile string
+	switch args[0] {
+	case "set":
+		if len(rest) == 0 {
+			fmt.Println(schemaUsage)
+			return
+		}
+		schemaFile, rest = rest[0], rest[1:]
+	case "show", "check", "rm":
+	default:
+		fmt.Println(schemaUsage)
+		return
+	}
+
+	var configFile string
+	if len(rest) > 0 {
+		configFile = rest[0]
+	}
+	config, ok := loadConfig(configFile)
+	if !ok {
+		return
+	}
+
+	switch args[0] {
+	case "set":
+		schema, err := os.ReadFile(schemaFile)
+		if err != nil {
+			fmt.Println("❌ Failure reading schema:", err)
+			return
+		}
+		if err := config.RegisterMetadataSchema(schema); err != nil {
+			fmt.Println("❌ Failure registering schema:", err)
+			return
+		}
+		fmt.Println("📐 Metadata schema registered for " + config.ProjectName)
+		if len(config.MetadataSchema) > 0 {
+			fmt.Println("⚠️ The config's own metadata_schema still takes precedence")
+		}
+
+	case "show":
+		schema, err := worker.FetchMetadataSchema(config.ProjectName)
+		if err != nil {
+			fmt.Println("❌ Failure fetching schema:", err)
+			return
+		}
+		if schema == nil {
+			fmt.Println("No metadata schema is registered for " + config.ProjectName)
+			return
+		}
+		fmt.Println(schema.Schema)
+
+	case "check":
+		violations, err := config.CheckMetadata()
+		if err != nil {
+			fmt.Println("❌ Check failed:", err)
+			return
+		}
+		if len(violations) == 0 {
+			fmt.Println("✅ Every object of " + config.ProjectName + " follows the metadata schema")
+			return
+		}
+		for _, v := range violations {
+			fmt.Printf("%d\t%s\t%s\n", v.ID, v.RelPath, v.Error)
+		}
+		fmt.Printf("❌ %d object(s) violate the metadata schema\n", len(violations))
+
+	case "rm":
+		removed, err := worker.DeleteMetadataSchema(config.ProjectName)
+		if err != nil {
+			fmt.Println("❌ Failure removing schema:", err)
+			return
+		}
+		if !removed {
+			fmt.Println("No metadata schema is registered for " + config.ProjectName)
+			return
+		}
+		fmt.Println("🗑️ Metadata schema of " + config.ProjectName + " removed")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+const searchUsage = "❌ Usage: search [-limit n] <query> [config_file] | search -reindex"
+
+// runSearch handles `cfs3 search`: it full-text searches the objects of the config's project.
+func runSearch(args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	limit := flags.Int("limit", 20, "maximum number of results")
+	reindex := flags.Bool("reindex", false, "rebuild the search index of every project")
+	flags.Parse(args)
+	rest := flags.Args()
+
+	if *reindex {
+		if err := worker.RebuildSearchIndex(); err != nil {
+			fmt.Println("❌ Failure rebuilding the search index:", err)
+			return
+		}
+		fmt.Println("🔎 Search index rebuilt")
+		return
+	}
+
+	if len(rest) == 0 || *limit <= 0 {
+		fmt.Println(searchUsage)
+		return
+	}
+
+	var configFile string
+	if len(rest) > 1 {
+		configFile = rest[1]
+	}
+	config, ok := loadConfig(configFile)
+	if !ok {
+		return
+	}
+
+	results, err := worker.SearchObjects(config.ProjectName, rest[0], *limit)
+	if err != nil {
+		fmt.Println("❌ Search failed:", err)
+		return
+	}
+	if len(results) == 0 {
+		fmt.Println("🔎 No objects match " + rest[0])
+		return
+	}
+	for _, r := range results {
+		fmt.Printf("%d\t%s\t%s\t%.2f\n", r.ID, r.RelPath, r.Name, r.Rank)
+	}
+}
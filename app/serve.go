@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3"
+	"github.com/Hack-Nocturne/cfs3/server"
+)
+
+// runServe handles `cfs3 serve`: a long-running daemon that batches patch/remove
+// operations received over HTTP (or a Unix socket) into as few deployments as possible.
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", "127.0.0.1:8787", "TCP address, or unix:/path/to/socket")
+	window := flags.Duration("window", 10*time.Second, "how long to collect operations before deploying")
+	maxFiles := flags.Int("max-files", 40, "deploy early once a batch holds this many files")
+	defaultsFile := flags.String("config", "", "optional config file with upload tuning and deployment budget")
+	root := flags.String("root", "", "directory local_file sources must lie in (local files are refused without it)")
+	allowURLs := flags.Bool("allow-urls", false, "accept http(s) sources")
+	flags.Parse(args)
+
+	opts := cfs3.CoalescerOptions{Window: *window, MaxFiles: *maxFiles}
+	if *defaultsFile != "" {
+		defaults, err := cfs3.NewCFS3ConfigFromFile(*defaultsFile)
+		if err != nil {
+			fmt.Println("❌ Failure loading config:", err)
+			return
+		}
+		opts.Defaults = defaults
+	}
+
+	coalescer := cfs3.NewCoalescer(opts)
+	serveUntilSignal(*listen, server.NewDaemonHandler(coalescer, server.DaemonOptions{Root: *root, AllowURLs: *allowURLs}), coalescer.Close)
+}
+
+// serveUntilSignal serves handler on addr until SIGINT/SIGTERM, then runs cleanup
+// (e.g. flushing queued operations) and shuts down gracefully.
+func serveUntilSignal(addr string, handler http.Handler, cleanup func()) {
+	listener, err := server.Listen(addr)
+	if err != nil {
+		fmt.Println("❌ Failure listening:", err)
+		return
+	}
+
+	srv := &http.Server{Handler: handler}
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+
+		// Flush first so that requests waiting on queued operations get their results.
+		fmt.Println("🛑 Shutting down...")
+		if cleanup != nil {
+			cleanup()
+		}
+		srv.Shutdown(context.Background())
+	}()
+
+	fmt.Println("🚀 Listening on " + addr)
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Println("❌ Server failed:", err)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+const snapshotUsage = "❌ Usage: snapshot create|diff|restore|rm <name> [config_file] | snapshot ls [config_file] |" +
+	" snapshot export <name> <file|-> [config_file] | snapshot import [-name n] <file|-> [config_file]"
+
+// runSnapshot handles `cfs3 snapshot ...`: it manages the named snapshots of the config's project.
+func runSnapshot(args []string) {
+	if len(args) == 0 {
+		fmt.Println(snapshotUsage)
+		return
+	}
+
+	flags := flag.NewFlagSet("snapshot "+args[0], flag.ExitOnError)
+	name := flags.String("name", "", "import under this name instead of the file's")
+	flags.Parse(args[1:])
+	rest := flags.Args()
+
+	// Positional arguments before the optional config file.
+	var positional int
+	switch args[0] {
+	case "ls":
+	case "create", "diff", "restore", "rm", "import":
+		positional = 1
+	case "export":
+		positional = 2
+	default:
+		fmt.Println(snapshotUsage)
+		return
+	}
+	if len(rest) < positional {
+		fmt.Println(snapshotUsage)
+		return
+	}
+
+	var configFile string
+	if len(rest) > positional {
+		configFile = rest[positional]
+	}
+	config, ok := loadConfig(configFile)
+	if !ok {
+		return
+	}
+
+	switch args[0] {
+	case "ls":
+		snapshots, err := worker.ListSnapshots(config.ProjectName)
+		if err != nil {
+			fmt.Println("❌ Failure listing snapshots:", err)
+			return
+		}
+		for _, s := range snapshots {
+			by := "-"
+			if s.By != nil {
+				by = *s.By
+			}
+			source := ""
+			if s.SourceProject != "" {
+				source = "\tfrom " + s.SourceProject
+			}
+			fmt.Printf("%s\t%s\t%s%s\n", s.Name, s.CreatedAt.Format(time.RFC3339), by, source)
+		}
+
+	case "create":
+		snapshot, err := config.CreateSnapshot(rest[0])
+		if err != nil {
+			fmt.Println("❌ Failure creating snapshot:", err)
+			return
+		}
+		fmt.Printf("📸 Snapshot %q of %s created\n", snapshot.Name, config.ProjectName)
+
+	case "rm":
+		deleted, err := worker.DeleteSnapshot(config.ProjectName, rest[0])
+		if err != nil {
+			fmt.Println("❌ Failure deleting snapshot:", err)
+			return
+		}
+		if !deleted {
+			fmt.Printf("❌ Snapshot %q not found in %s\n", rest[0], config.ProjectName)
+			return
+		}
+		fmt.Printf("🗑️ Snapshot %q deleted\n", rest[0])
+
+	case "diff":
+		diff, err := config.DiffSnapshot(rest[0])
+		if err != nil {
+			fmt.Println("❌ Failure diffing snapshot:", err)
+			return
+		}
+		if diff.Clean() {
+			fmt.Printf("✅ %s matches snapshot %q\n", config.ProjectName, rest[0])
+			return
+		}
+		for _, p := range diff.Added {
+			fmt.Println("+ " + p)
+		}
+		for _, p := range diff.Removed {
+			fmt.Println("- " + p)
+		}
+		for _, p := range diff.Changed {
+			fmt.Println("~ " + p)
+		}
+
+	case "restore":
+		result, err := config.RestoreSnapshot(rest[0])
+		if err != nil {
+			fmt.Println("❌ Snapshot restore failed:", err)
+			return
+		}
+		if result.DeploymentID == "" {
+			fmt.Printf("✅ %s already matches snapshot %q\n", config.ProjectName, rest[0])
+			return
+		}
+		fmt.Printf("📸 %s is back at snapshot %q: %d object(s) restored, %d removed\n",
+			config.ProjectName, rest[0], len(result.Objects), len(result.Removed))
+		fmt.Println("🌐 Take a peek over " + result.URL)
+
+	case "export":
+		var w io.Writer = os.Stdout
+		if rest[1] != "-" {
+			f, err := os.Create(rest[1])
+			if err != nil {
+				fmt.Println("❌ Failure creating export file:", err)
+				return
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := config.ExportSnapshot(rest[0], w); err != nil {
+			fmt.Println("❌ Failure exporting snapshot:", err)
+			return
+		}
+		if rest[1] != "-" {
+			fmt.Printf("📦 Snapshot %q exported to %s\n", rest[0], rest[1])
+		}
+
+	case "import":
+		var r io.Reader = os.Stdin
+		if rest[0] != "-" {
+			f, err := os.Open(rest[0])
+			if err != nil {
+				fmt.Println("❌ Failure opening snapshot file:", err)
+				return
+			}
+			defer f.Close()
+			r = f
+		}
+		snapshot, err := config.ImportSnapshot(r, *name)
+		if err != nil {
+			fmt.Println("❌ Failure importing snapshot:", err)
+			return
+		}
+		fmt.Printf("📥 Snapshot %q imported into %s, restore it with `snapshot restore %s`\n",
+			snapshot.Name, config.ProjectName, snapshot.Name)
+	}
+}
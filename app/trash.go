@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// runTrash handles `cfs3 trash ls|purge [-all] [config_file]`: it lists or empties the trash of
+// the config's project.
+func runTrash(args []string) {
+	if len(args) == 0 || (args[0] != "ls" && args[0] != "purge") {
+		fmt.Println("❌ Usage: trash ls [config_file] | trash purge [-all] [config_file]")
+		return
+	}
+
+	flags := flag.NewFlagSet("trash "+args[0], flag.ExitOnError)
+	all := flags.Bool("all", false, "purge every trashed object, not only expired ones")
+	flags.Parse(args[1:])
+
+	config, ok := loadConfig(flags.Arg(0))
+	if !ok {
+		return
+	}
+
+	if args[0] == "purge" {
+		purged, err := config.PurgeTrash(*all)
+		if err != nil {
+			fmt.Println("❌ Failure purging trash:", err)
+			return
+		}
+		fmt.Printf("🗑️ Purged %d object(s) from the trash of %s\n", purged, config.ProjectName)
+		return
+	}
+
+	objects, err := worker.ListTrashedObjects(config.ProjectName)
+	if err != nil {
+		fmt.Println("❌ Failure listing trash:", err)
+		return
+	}
+	for _, obj := range objects {
+		expires := obj.DeletedAt.Time.Add(config.TrashRetention())
+		fmt.Printf("#%d\tremoved %s\texpires %s\t%s\n",
+			obj.ID, obj.DeletedAt.Time.Format(time.RFC3339), expires.Format(time.RFC3339), obj.RelPath)
+	}
+}
+
+// runUndelete handles `cfs3 undelete <id>[,<id>…] [config_file]`: it takes objects out of the
+// trash and deploys them again.
+func runUndelete(args []string) {
+	if len(args) == 0 {
+		fmt.Println("❌ Usage: undelete <id>[,<id>…] [config_file]")
+		return
+	}
+
+	var ids []int64
+	for _, field := range strings.Split(args[0], ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+		if err != nil {
+			fmt.Println("❌ Invalid object id:", field)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	var configFile string
+	if len(args) > 1 {
+		configFile = args[1]
+	}
+	config, ok := loadConfig(configFile)
+	if !ok {
+		return
+	}
+
+	result, err := config.Undelete(ids)
+	if err != nil {
+		fmt.Println("❌ Failure undeleting objects:", err)
+		return
+	}
+	for _, obj := range result.Objects {
+		fmt.Printf("♻️ Undeleted #%d %s\n", obj.ID, obj.RelPath)
+	}
+}
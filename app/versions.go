@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+const versionsUsage = "❌ Usage: versions ls [-path p] [config_file] | versions restore [-path p] <version_id> [config_file]"
+
+// runVersions handles `cfs3 versions ls|restore`: it lists the object history of the config's
+// project or restores one version of an object.
+func runVersions(args []string) {
+	if len(args) == 0 || (args[0] != "ls" && args[0] != "restore") {
+		fmt.Println(versionsUsage)
+		return
+	}
+
+	flags := flag.NewFlagSet("versions "+args[0], flag.ExitOnError)
+	relPath := flags.String("path", "", "only list this path / restore to this path instead of the original one")
+	flags.Parse(args[1:])
+	rest := flags.Args()
+
+	var versionId int64
+	if args[0] == "restore" {
+		if len(rest) == 0 {
+			fmt.Println(versionsUsage)
+			return
+		}
+		id, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			fmt.Println("❌ Invalid version id:", rest[0])
+			return
+		}
+		versionId, rest = id, rest[1:]
+	}
+
+	var configFile string
+	if len(rest) > 0 {
+		configFile = rest[0]
+	}
+	config, ok := loadConfig(configFile)
+	if !ok {
+		return
+	}
+
+	if args[0] == "ls" {
+		versions, err := worker.ListObjectVersions(config.ProjectName, *relPath)
+		if err != nil {
+			fmt.Println("❌ Failure listing versions:", err)
+			return
+		}
+		for _, v := range versions {
+			by := "-"
+			if v.AddedBy != nil {
+				by = *v.AddedBy
+			}
+			fmt.Printf("#%d\t%s\t%s\t%s\t%s\t%s\n", v.ID, v.CreatedAt.Format(time.RFC3339), v.Action, v.Hash, by, v.RelPath)
+		}
+		return
+	}
+
+	result, err := config.RestoreVersion(versionId, *relPath)
+	if err != nil {
+		fmt.Println("❌ Failure restoring version:", err)
+		return
+	}
+	for _, obj := range result.Objects {
+		fmt.Printf("♻️ Restored version #%d as object #%d at %s\n", versionId, obj.ID, obj.RelPath)
+	}
+}
@@ -0,0 +1,210 @@
+package cfs3
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Hack-Nocturne/cfs3/types"
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/vars"
+)
+
+// extractedEntry is an archive entry that was hashed while it was extracted.
+type extractedEntry struct {
+	path   string
+	hashes utils.FileHashes
+	asset  types.Asset
+}
+
+// expandArchive streams through the archive of a patch, extracting every regular file into
+// the staging dir, and returns one exact-path patch per entry below the patch's remote_dir.
+// Entries matching the ignore patterns are skipped.
+func (c *CFS3Config) expandArchive(i int, fp FilePatch) ([]FilePatch, error) {
+	f, err := os.Open(fp.LocalFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir := filepath.Join(c.stagingDir, "archives", strconv.Itoa(i))
+	var patches []FilePatch
+	index := make(map[string]int) // later entries with the same path win
+
+	extract := func(name string, r io.Reader) error {
+		// Cleaning the path as if it were rooted keeps "../" entries inside the extraction dir.
+		rel := strings.Trim(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+		if rel == "" {
+			return nil
+		}
+		for p := rel; p != "."; p = path.Dir(p) {
+			if utils.ShouldIgnore(p) {
+				return nil
+			}
+		}
+
+		entry, err := extractEntry(filepath.Join(dir, filepath.FromSlash(rel)), r)
+		if err != nil {
+			return fmt.Errorf("entry %q: %w", name, err)
+		}
+
+		patch := FilePatch{
+			LocalFile:  entry.path,
+			RemotePath: path.Join(fp.Remote, rel),
+			Name:       path.Base(rel),
+			Metadata:   fp.Metadata,
+			extracted:  entry,
+		}
+		if j, ok := index[rel]; ok {
+			patches[j] = patch
+			return nil
+		}
+		if len(patches) >= vars.MAX_ASSET_COUNT {
+			return fmt.Errorf("number of entries exceeds maximum allowed %d", vars.MAX_ASSET_COUNT)
+		}
+		index[rel] = len(patches)
+		patches = append(patches, patch)
+		return nil
+	}
+
+	switch format, err := sniffArchive(f); {
+	case err != nil:
+		return nil, err
+	case format == "zip":
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		for _, zf := range zr.File {
+			if !zf.Mode().IsRegular() {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, err
+			}
+			err = extract(zf.Name, rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	case format == "tar.gz":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		if err := walkTar(tar.NewReader(gz), extract); err != nil {
+			return nil, err
+		}
+	case format == "tar":
+		if err := walkTar(tar.NewReader(f), extract); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unsupported archive format, expected zip, tar or tar.gz")
+	}
+
+	if len(patches) == 0 {
+		return nil, errors.New("archive contains no files")
+	}
+	return patches, nil
+}
+
+// sniffArchive detects the archive format from its leading bytes and rewinds f.
+func sniffArchive(f *os.File) (string, error) {
+	header := make([]byte, 262)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	header = header[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return "zip", nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return "tar.gz", nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return "tar", nil
+	case strings.EqualFold(filepath.Ext(f.Name()), ".tar"):
+		return "tar", nil // pre-POSIX tar without a magic number
+	}
+	return "", nil
+}
+
+func walkTar(tr *tar.Reader, extract func(name string, r io.Reader) error) error {
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if hdr.Size > vars.MAX_ASSET_SIZE {
+			return fmt.Errorf("entry %q is %d bytes, exceeds maximum allowed %d bytes", hdr.Name, hdr.Size, vars.MAX_ASSET_SIZE)
+		}
+		if err := extract(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// extractEntry writes r to dest while hashing it, so every entry is read exactly once.
+func extractEntry(dest string, r io.Reader) (*extractedEntry, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return nil, err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	// Read one byte past the limit so oversized entries are detected whatever their header claims.
+	hashes, err := utils.HashReader(io.TeeReader(io.LimitReader(r, vars.MAX_ASSET_SIZE+1), out), strings.TrimPrefix(filepath.Ext(dest), "."))
+	if err != nil {
+		return nil, err
+	}
+	size, err := out.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if size > vars.MAX_ASSET_SIZE {
+		return nil, fmt.Errorf("exceeds maximum allowed %d bytes", vars.MAX_ASSET_SIZE)
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+
+	return &extractedEntry{
+		path:   dest,
+		hashes: hashes,
+		asset: types.Asset{
+			Size: size,
+			Hash: hashes.Blake3,
+			Open: func() (io.ReadCloser, error) { return os.Open(dest) },
+		},
+	}, nil
+}
@@ -0,0 +1,123 @@
+package cfs3
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3/vars"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// BudgetPolicy decides what happens when a run would exceed the daily deployment limit.
+type BudgetPolicy string
+
+const (
+	BudgetRefuse BudgetPolicy = "refuse" // fail before anything is uploaded (default)
+	BudgetQueue  BudgetPolicy = "queue"  // wait until a deployment slot frees up
+)
+
+// ErrDeployBudgetExceeded is returned when a run would exceed the project's daily deployment limit.
+var ErrDeployBudgetExceeded = errors.New("daily deployment budget exhausted")
+
+// DeployBudget describes how many deployments a project has left in the rolling 24h window.
+type DeployBudget struct {
+	Limit     int       // 0 means unlimited
+	Used      int       // deployments recorded within the window
+	Remaining int       // -1 when unlimited
+	ResetsAt  time.Time // when the oldest deployment in the window ages out, zero if none
+}
+
+// FetchDeployBudget computes the deployment budget of a project from the deployments recorded in D1.
+func FetchDeployBudget(projName string, limit int) (DeployBudget, error) {
+	since := time.Now().Add(-vars.DEPLOY_BUDGET_WINDOW)
+
+	used, err := worker.CountDeploymentsSince(projName, since)
+	if err != nil {
+		return DeployBudget{}, fmt.Errorf("counting deployments: %w", err)
+	}
+
+	budget := DeployBudget{Limit: limit, Used: int(used), Remaining: -1}
+	if limit > 0 {
+		budget.Remaining = max(limit-int(used), 0)
+	}
+
+	oldest, err := worker.OldestDeploymentSince(projName, since)
+	if err != nil {
+		return DeployBudget{}, fmt.Errorf("fetching oldest deployment: %w", err)
+	}
+	if oldest != nil {
+		budget.ResetsAt = oldest.CreatedAt.Add(vars.DEPLOY_BUDGET_WINDOW)
+	}
+
+	return budget, nil
+}
+
+// checkDeployBudget makes sure the run fits in the daily deployment limit, either refusing
+// with ErrDeployBudgetExceeded or waiting for a free slot depending on OnBudgetExceeded.
+func (c *CFS3Config) checkDeployBudget() error {
+	if c.DailyDeployLimit <= 0 {
+		return nil
+	}
+
+	for {
+		budget, err := FetchDeployBudget(c.ProjectName, c.DailyDeployLimit)
+		if err != nil {
+			return err
+		}
+		if budget.Remaining > 0 {
+			return nil
+		}
+
+		if c.OnBudgetExceeded != BudgetQueue {
+			return fmt.Errorf("%w: %d/%d deployments in the last 24h, next slot at %s",
+				ErrDeployBudgetExceeded, budget.Used, budget.Limit, budget.ResetsAt.Format(time.RFC3339))
+		}
+
+		fmt.Println("⏳ Daily deployment budget exhausted, queued until " + budget.ResetsAt.Format(time.RFC3339))
+		time.Sleep(max(time.Until(budget.ResetsAt), time.Second))
+	}
+}
+
+// recordDeployment stores a finished deployment in D1 so it counts against the budget.
+func (c *CFS3Config) recordDeployment(mode CFS3Mode, deploymentId, url string) {
+	by := c.By
+	err := worker.RecordDeployment(&worker.Deployment{
+		ID:          deploymentId,
+		ProjectName: c.ProjectName,
+		URL:         url,
+		Mode:        string(mode),
+		By:          &by,
+	})
+	if err != nil {
+		fmt.Println("⚠️ Failed to record deployment:", err)
+		return
+	}
+
+	if c.DailyDeployLimit > 0 {
+		if budget, err := FetchDeployBudget(c.ProjectName, c.DailyDeployLimit); err == nil {
+			fmt.Printf("📊 Deployment budget: %d/%d remaining today\n", budget.Remaining, budget.Limit)
+		}
+	}
+}
+
+// recordDeploymentObjects stores the D1 changes of a recorded deployment once they are written.
+// The deployment is pinned to the newest of the versions it wrote, so later writes of other
+// runs are not taken for part of its state; one that wrote none deployed D1 as it is.
+func (c *CFS3Config) recordDeploymentObjects(deploymentId string, added, removed, versionIds []int64) {
+	var lastVersionId int64
+	if len(versionIds) > 0 {
+		lastVersionId = slices.Max(versionIds)
+	} else {
+		var err error
+		if lastVersionId, err = worker.LastVersionID(c.ProjectName); err != nil {
+			fmt.Println("⚠️ Failed to record deployment objects:", err)
+			return
+		}
+	}
+
+	if err := worker.SetDeploymentObjects(deploymentId, added, removed, lastVersionId); err != nil {
+		fmt.Println("⚠️ Failed to record deployment objects:", err)
+	}
+}
@@ -3,8 +3,12 @@ package cfs3
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 
 	"github.com/Hack-Nocturne/cfs3/types"
 	"github.com/Hack-Nocturne/cfs3/utils"
@@ -18,13 +22,57 @@ const (
 	ModePatch  CFS3Mode = "patch"
 	ModeRemove CFS3Mode = "remove"
 	ModeList   CFS3Mode = "list"
+	// ModeMeta changes the metadata of existing objects in D1 without deploying, see MetaUpdate.
+	ModeMeta CFS3Mode = "meta"
 )
 
 // FilePatch represents a single patch operation.
 type FilePatch struct {
+	// LocalFile is a path on the local disk, "-" for stdin or an http(s) URL.
 	LocalFile string         `json:"local_file"`
 	Remote    string         `json:"remote_dir"`
 	Metadata  map[string]any `json:"metadata"`
+
+	// RemotePath stores the file at this exact path instead of "<remote_dir>/<sha1>.<ext>".
+	// Patching an existing path replaces its content.
+	RemotePath string `json:"remote_path,omitempty"`
+	// Name is the file name shown to downloaders, defaults to the base name of local_file.
+	Name string `json:"name,omitempty"`
+
+	// Library-only alternatives to a file on the local disk. Reader supplies the content
+	// directly and requires Name; Size, when non-zero, is checked against what it yields.
+	// FS makes LocalFile a path inside that file system, e.g. an embed.FS.
+	Reader io.Reader `json:"-"`
+	Size   int64     `json:"-"`
+	FS     fs.FS     `json:"-"`
+	// Temporary marks LocalFile as a spooled copy that is deleted after the run. It is hashed
+	// without the hash cache, its entry would never be looked up again.
+	Temporary bool `json:"-"`
+
+	// Type "archive" treats local_file as a zip, tar or tar.gz archive whose entries are
+	// stored below remote_dir at their relative paths.
+	Type SourceType `json:"type,omitempty"`
+
+	// OnConflict overrides the config's on_conflict for this file.
+	OnConflict ConflictPolicy `json:"on_conflict,omitempty"`
+
+	extracted *extractedEntry // set on the patches an archive expands into
+}
+
+// SourceType selects how the local_file of a patch is read.
+type SourceType string
+
+const (
+	SourceFile    SourceType = "file"
+	SourceArchive SourceType = "archive"
+)
+
+// fileName returns the display name of the patched file.
+func (fp FilePatch) fileName() string {
+	if fp.Name != "" {
+		return fp.Name
+	}
+	return filepath.Base(fp.LocalFile)
 }
 
 // CFS3Config represents the top-level configuration.
@@ -35,9 +83,53 @@ type CFS3Config struct {
 	Headers     map[string]string `json:"headers,omitempty"`
 	FilesPatch  []FilePatch       `json:"files__patch,omitempty"`
 	FilesRemove []int64           `json:"files__remove,omitempty"`
+	FilesMeta   []MetaUpdate      `json:"files__meta,omitempty"`
+
+	// What patches do to paths that already hold an object, "overwrite" by default.
+	OnConflict ConflictPolicy `json:"on_conflict,omitempty"`
+
+	// Selection of list mode, and of objects to remove in addition to files__remove: the
+	// objects below Prefix whose metadata matches every predicate of Where.
+	Prefix string                 `json:"prefix,omitempty"`
+	Where  []worker.MetaPredicate `json:"where,omitempty"`
+
+	// Upload tuning: the maximum number of concurrent bucket uploads (concurrency adapts below it
+	// on 429/5xx responses) and an optional bandwidth cap shared by all uploads.
+	UploadConcurrency       int   `json:"upload_concurrency,omitempty"`
+	MaxUploadBytesPerSecond int64 `json:"max_upload_bytes_per_second,omitempty"`
+
+	// Daily deployment budget per project (0 disables it) and what to do once it is used up.
+	DailyDeployLimit int          `json:"daily_deploy_limit,omitempty"`
+	OnBudgetExceeded BudgetPolicy `json:"on_budget_exceeded,omitempty"`
+
+	// Removed objects stay recoverable in the trash for this many days (default 30).
+	TrashRetentionDays int `json:"trash_retention_days,omitempty"`
+
+	// JSON Schema the metadata of patched and updated objects must follow. It takes
+	// precedence over the schema registered for the project in D1.
+	MetadataSchema json.RawMessage `json:"metadata_schema,omitempty"`
 
 	isProcessed bool
+	stagingDir  string
+	files       map[string]string
+	assets      map[string]types.Asset
 	metadata    map[string]types.FileContainer
+	hashes      map[string]string        // asset hashes of the patched files, by remote path
+	previous    map[string]worker.Object // objects at the patched paths before the run
+	statuses    map[string]FileStatus
+	result      *ApplyResult
+
+	schema       *utils.JSONSchema
+	schemaLoaded bool
+}
+
+// ApplyResult describes the outcome of Apply.
+type ApplyResult struct {
+	DeploymentID string          `json:"deployment_id"`
+	URL          string          `json:"url"`
+	Objects      []worker.Object `json:"objects,omitempty"` // D1 rows of the patched files
+	Removed      []int64         `json:"removed,omitempty"` // IDs of the removed objects
+	Files        []FileReport    `json:"files,omitempty"`   // what each patched file did
 }
 
 // NewCFS3ConfigFromFile reads a JSON file, unmarshals into struct and creates cfs3 config instance.
@@ -55,30 +147,60 @@ func NewCFS3ConfigFromFile(path string) (*CFS3Config, error) {
 	return &cfg, nil
 }
 
-func (c *CFS3Config) Process() error {
+func (c *CFS3Config) Process() (err error) {
 	if c.isProcessed {
 		return nil
 	}
 	c.isProcessed = true
 
-	if err := c.validate(); err != nil {
+	if err = c.validate(); err != nil {
 		return fmt.Errorf("invalid config: %w", err)
 	}
 
-	fileMap, err := c.processPatchFiles(vars.UPLOAD_BASE_DIR)
+	// Metadata lives only in D1, there is nothing to deploy.
+	if c.Mode == ModeMeta || c.Mode == ModeList {
+		return nil
+	}
+
+	if err = c.selectRemovals(); err != nil {
+		return err
+	}
+	if err = c.checkRemovals(); err != nil {
+		return err
+	}
+
+	if err = c.checkDeployBudget(); err != nil {
+		return err
+	}
+
+	// Only the generated _headers file is staged; assets are deployed straight from their source paths.
+	stagingDir, err := os.MkdirTemp("", vars.STAGING_DIR_PATTERN)
+	if err != nil {
+		return fmt.Errorf("error creating staging dir: %w", err)
+	}
+	c.stagingDir = stagingDir
+	defer func() {
+		if err != nil {
+			os.RemoveAll(c.stagingDir)
+		}
+	}()
+
+	fileMap, err := c.processPatchFiles()
 	if err != nil {
 		return fmt.Errorf("error processing patch files: %w", err)
 	}
+	if c.Mode == ModePatch {
+		if err = c.resolveConflicts(fileMap); err != nil {
+			return err
+		}
+	}
+
+	c.addDefaultHeaders()
 
-	if err := c.createHeadersFile(vars.UPLOAD_BASE_DIR, fileMap); err != nil {
+	if err = c.createHeadersFile(c.stagingDir, fileMap); err != nil {
 		return fmt.Errorf("error creating headers file: %w", err)
 	}
 
-	// Add default headers
-	c.Headers["x-powered-by"] = "CFS3"
-	c.Headers["x-developed-by"] = "Rishabh Kumar"
-	c.Headers["x-contact-email"] = "rishabh.kumar.pro@gmail.com"
-
 	// The trick here is to include existing files metadata used by Cloudflare, then
 	// a) For patch mode, we add new files to existing metadata
 	// b) For remove mode, we exclude the removed files from existing metadata
@@ -107,18 +229,36 @@ func (c *CFS3Config) Apply() error {
 	if !c.isProcessed {
 		return fmt.Errorf("use Process() method before Apply()")
 	}
+	switch c.Mode {
+	case ModeMeta:
+		return c.applyMeta()
+	case ModeList:
+		return c.applyList()
+	}
+
+	defer func() { os.RemoveAll(c.stagingDir) }()
 
-	os.MkdirAll(vars.UPLOAD_BASE_DIR, 0o755)
-	defer func() { os.RemoveAll(vars.UPLOAD_BASE_DIR) }()
+	if c.Mode == ModePatch && len(c.files) == 0 && len(c.assets) == 0 {
+		c.result = &ApplyResult{Objects: slices.Collect(maps.Values(c.previous)), Files: c.fileReports()}
+		printFileReports(c.result.Files)
+		fmt.Println("⏭️ Every file was skipped, nothing to deploy")
+		return nil
+	}
 
 	uploadArgs := types.PagesDeployOptions{
-		Directory:   vars.UPLOAD_BASE_DIR,
+		Directory:   c.stagingDir,
 		AccountId:   vars.CF_ACCOUNT_ID,
 		ProjectName: c.ProjectName,
 		SkipCaching: false,
+		Files:       c.files,
+		Assets:      c.assets,
+		Existing:    c.metadata,
+
+		Concurrency:       c.UploadConcurrency,
+		MaxBytesPerSecond: c.MaxUploadBytesPerSecond,
 	}
 
-	deployResp, fileMap, err := utils.Deploy(uploadArgs, c.Mode == "patch")
+	deployResp, fileMap, err := utils.Deploy(uploadArgs, c.Mode == ModePatch)
 	if err != nil {
 		fmt.Println("❌ Deployment failed: " + err.Error())
 		return err
@@ -126,7 +266,66 @@ func (c *CFS3Config) Apply() error {
 
 	fmt.Println("💫 Deployment completed with ID: " + deployResp.ID)
 	fmt.Println("🌐 Take a peek over " + deployResp.URL)
+	c.recordDeployment(c.Mode, deployResp.ID, deployResp.URL)
+
 	maps.Copy(c.metadata, fileMap)
 
-	return c.upsertMetadata()
+	c.result = &ApplyResult{DeploymentID: deployResp.ID, URL: deployResp.URL}
+	versionIds, err := c.upsertMetadata()
+	if err != nil {
+		return err
+	}
+
+	switch c.Mode {
+	case ModePatch:
+		remotes := make([]string, len(c.FilesPatch))
+		for i, fp := range c.FilesPatch {
+			remotes[i] = fp.Remote
+		}
+
+		objects, err := worker.FetchObjectsByPaths(c.ProjectName, remotes)
+		if err != nil {
+			return fmt.Errorf("failure fetching patched objects: %w", err)
+		}
+		c.result.Objects = objects
+		c.result.Files = c.fileReports()
+		printFileReports(c.result.Files)
+	case ModeRemove:
+		c.result.Removed = c.FilesRemove
+	}
+	c.recordDeploymentObjects(deployResp.ID, objectIDs(c.result.Objects), c.result.Removed, versionIds)
+
+	if c.Mode == ModeRemove {
+		if purged, err := c.PurgeTrash(false); err != nil {
+			fmt.Println("⚠️ Failed to purge expired trash:", err)
+		} else if purged > 0 {
+			fmt.Printf("🗑️ Purged %d expired object(s) from the trash\n", purged)
+		}
+	}
+
+	return nil
+}
+
+// addDefaultHeaders adds the headers every deployment is served with.
+func (c *CFS3Config) addDefaultHeaders() {
+	if c.Headers == nil {
+		c.Headers = make(map[string]string)
+	}
+	c.Headers["x-powered-by"] = "CFS3"
+	c.Headers["x-developed-by"] = "Rishabh Kumar"
+	c.Headers["x-contact-email"] = "rishabh.kumar.pro@gmail.com"
+}
+
+// objectIDs returns the IDs of the given objects.
+func objectIDs(objects []worker.Object) []int64 {
+	ids := make([]int64, len(objects))
+	for i, obj := range objects {
+		ids[i] = obj.ID
+	}
+	return ids
+}
+
+// Result returns the outcome of a successful Apply, or nil before that.
+func (c *CFS3Config) Result() *ApplyResult {
+	return c.result
 }
@@ -0,0 +1,248 @@
+package cfs3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// ErrCoalescerClosed is returned for operations submitted after Close.
+var ErrCoalescerClosed = errors.New("coalescer is closed")
+
+// CoalescerOptions configures how operations are batched into deployments.
+type CoalescerOptions struct {
+	Window   time.Duration // flush a batch this long after its first operation arrived
+	MaxFiles int           // flush early once a batch holds this many files
+	Defaults *CFS3Config   // upload tuning and deployment budget applied to every batch (optional)
+}
+
+// Coalescer batches patch and remove operations into as few deployments as possible.
+// Operations are grouped by project, mode, uploader and headers, and batches are deployed one at
+// a time so that concurrent flushes never race on a project's D1 state.
+type Coalescer struct {
+	opts CoalescerOptions
+
+	mu      sync.Mutex
+	closed  bool
+	batches map[batchKey]*batch
+
+	flushMu sync.Mutex
+	wg      sync.WaitGroup
+}
+
+type batchKey struct {
+	project string
+	mode    CFS3Mode
+	by      string
+	headers string // canonical form of the operation's headers, they apply to the whole deployment
+}
+
+type batch struct {
+	key   batchKey
+	ops   []*pendingOp
+	files int
+	timer *time.Timer
+}
+
+type pendingOp struct {
+	config *CFS3Config
+	done   chan struct{}
+	result *ApplyResult
+	err    error
+}
+
+// NewCoalescer creates a Coalescer. MaxFiles is capped at the per-run patch limit.
+func NewCoalescer(opts CoalescerOptions) *Coalescer {
+	if opts.MaxFiles <= 0 || opts.MaxFiles > maxPatchFiles {
+		opts.MaxFiles = maxPatchFiles
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+
+	return &Coalescer{opts: opts, batches: make(map[batchKey]*batch)}
+}
+
+// Submit queues a patch or remove operation and blocks until the deployment it was batched
+// into has been applied. The result only lists the objects of this operation.
+// If ctx ends first the operation still runs, but its result is no longer reported.
+func (co *Coalescer) Submit(ctx context.Context, op *CFS3Config) (*ApplyResult, error) {
+	if op.Mode != ModePatch && op.Mode != ModeRemove {
+		return nil, errors.New("only 'patch' and 'remove' operations can be queued")
+	}
+	if err := op.validate(); err != nil {
+		return nil, fmt.Errorf("invalid operation: %w", err)
+	}
+	if len(op.Where) > 0 {
+		return nil, errors.New("metadata selections can't be queued, pass object ids")
+	}
+	for _, fp := range op.FilesPatch {
+		if fp.isStdin() {
+			return nil, errors.New("stdin sources can't be queued")
+		}
+		if fp.Type == SourceArchive {
+			return nil, errors.New("archive sources can't be queued")
+		}
+	}
+
+	p := &pendingOp{config: op, done: make(chan struct{})}
+	co.enqueue(p)
+
+	select {
+	case <-p.done:
+		return p.result, p.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close flushes every pending batch and waits for all deployments to finish.
+func (co *Coalescer) Close() {
+	co.mu.Lock()
+	co.closed = true
+	for _, b := range co.batches {
+		co.detach(b)
+	}
+	co.mu.Unlock()
+
+	co.wg.Wait()
+}
+
+func (co *Coalescer) enqueue(p *pendingOp) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	if co.closed {
+		p.err = ErrCoalescerClosed
+		close(p.done)
+		return
+	}
+
+	key := batchKey{
+		project: p.config.ProjectName,
+		mode:    p.config.Mode,
+		by:      p.config.By,
+		headers: headersKey(p.config.Headers),
+	}
+	size := len(p.config.FilesPatch) + len(p.config.FilesRemove)
+
+	b := co.batches[key]
+	if b != nil && b.files+size > co.opts.MaxFiles {
+		co.detach(b)
+		b = nil
+	}
+	if b == nil {
+		b = &batch{key: key}
+		co.batches[key] = b
+		b.timer = time.AfterFunc(co.opts.Window, func() {
+			co.mu.Lock()
+			defer co.mu.Unlock()
+			if co.batches[key] == b {
+				co.detach(b)
+			}
+		})
+	}
+
+	b.ops = append(b.ops, p)
+	b.files += size
+	if b.files >= co.opts.MaxFiles {
+		co.detach(b)
+	}
+}
+
+// headersKey returns a canonical string of headers, equal for equal maps.
+func headersKey(headers map[string]string) string {
+	var sb strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		sb.WriteString(name)
+		sb.WriteByte(0)
+		sb.WriteString(headers[name])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// detach removes the batch from the queue and schedules its deployment. Callers must hold co.mu.
+func (co *Coalescer) detach(b *batch) {
+	delete(co.batches, b.key)
+	b.timer.Stop()
+
+	co.wg.Add(1)
+	go co.flush(b)
+}
+
+// flush deploys a batch as a single Process/Apply run and hands each operation its share of the result.
+func (co *Coalescer) flush(b *batch) {
+	defer co.wg.Done()
+
+	co.flushMu.Lock()
+	defer co.flushMu.Unlock()
+
+	cfg := &CFS3Config{
+		By:          b.key.by,
+		Mode:        b.key.mode,
+		ProjectName: b.key.project,
+		Headers:     maps.Clone(b.ops[0].config.Headers), // the same for every operation of the batch
+	}
+	if d := co.opts.Defaults; d != nil {
+		cfg.UploadConcurrency = d.UploadConcurrency
+		cfg.MaxUploadBytesPerSecond = d.MaxUploadBytesPerSecond
+		cfg.DailyDeployLimit = d.DailyDeployLimit
+		cfg.OnBudgetExceeded = d.OnBudgetExceeded
+	}
+
+	offsets := make([]int, len(b.ops))
+	for i, p := range b.ops {
+		offsets[i] = len(cfg.FilesPatch)
+		for _, fp := range p.config.FilesPatch {
+			// The batch has no policy of its own, keep each operation's on the file.
+			fp.OnConflict = fp.conflictPolicy(p.config.OnConflict)
+			cfg.FilesPatch = append(cfg.FilesPatch, fp)
+		}
+		cfg.FilesRemove = append(cfg.FilesRemove, p.config.FilesRemove...)
+	}
+
+	fmt.Printf("📦 Flushing %d queued %s operation(s) for %s\n", len(b.ops), b.key.mode, b.key.project)
+	err := cfg.Process()
+	if err == nil {
+		err = cfg.Apply()
+	}
+
+	var objectsByPath map[string]worker.Object
+	if err == nil {
+		objectsByPath = make(map[string]worker.Object, len(cfg.Result().Objects))
+		for _, obj := range cfg.Result().Objects {
+			objectsByPath[obj.RelPath] = obj
+		}
+	}
+
+	for i, p := range b.ops {
+		if err != nil {
+			p.err = err
+			close(p.done)
+			continue
+		}
+
+		result := &ApplyResult{
+			DeploymentID: cfg.Result().DeploymentID,
+			URL:          cfg.Result().URL,
+			Removed:      p.config.FilesRemove,
+		}
+		for j, fp := range cfg.FilesPatch[offsets[i] : offsets[i]+len(p.config.FilesPatch)] {
+			if obj, ok := objectsByPath[fp.Remote]; ok {
+				result.Objects = append(result.Objects, obj)
+			}
+			result.Files = append(result.Files, cfg.Result().Files[offsets[i]+j])
+		}
+
+		p.result = result
+		close(p.done)
+	}
+}
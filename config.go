@@ -1,12 +1,10 @@
 package cfs3
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"net/url"
 	"os"
 	"path"
@@ -16,9 +14,13 @@ import (
 
 	"github.com/Hack-Nocturne/cfs3/types"
 	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/vars"
 	"github.com/Hack-Nocturne/cfs3/worker"
 )
 
+// maxPatchFiles is the maximum number of files__patch entries in a single run.
+const maxPatchFiles = 40
+
 // validate enforces required fields and mode-specific constraints.
 func (c *CFS3Config) validate() error {
 	if c.By == "" {
@@ -33,19 +35,66 @@ func (c *CFS3Config) validate() error {
 			return errors.New("mode 'patch' requires non-empty files__patch")
 		}
 
-		if len(c.FilesPatch) > 40 {
-			return errors.New("mode 'patch' supports a maximum of 40 files in files__patch in one run")
+		if len(c.FilesPatch) > maxPatchFiles {
+			return fmt.Errorf("mode 'patch' supports a maximum of %d files in files__patch in one run", maxPatchFiles)
 		}
 	case ModeRemove:
-		if len(c.FilesRemove) == 0 {
-			return errors.New("mode 'remove' requires non-empty files__remove")
+		if len(c.FilesRemove) == 0 && len(c.Where) == 0 {
+			return errors.New("mode 'remove' requires non-empty files__remove or where")
+		}
+	case ModeMeta:
+		if len(c.FilesMeta) == 0 {
+			return errors.New("mode 'meta' requires non-empty files__meta")
+		}
+		for i, u := range c.FilesMeta {
+			if err := u.validate(); err != nil {
+				return fmt.Errorf("files__meta[%d]: %w", i, err)
+			}
 		}
 	case ModeList:
-		return errors.New("mode 'list' currently not implemented")
 	default:
 		return errors.New("mode unknown")
 	}
 
+	if (len(c.Where) > 0 || c.Prefix != "") && c.Mode != ModeList && c.Mode != ModeRemove {
+		return errors.New("fields 'prefix' and 'where' are only supported in modes 'list' and 'remove'")
+	}
+	if c.Mode == ModeRemove && c.Prefix != "" && len(c.Where) == 0 {
+		return errors.New("field 'prefix' narrows 'where' in mode 'remove', it requires non-empty where")
+	}
+	for i, p := range c.Where {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("where[%d]: %w", i, err)
+		}
+	}
+	if err := worker.CheckMetaQueryParams(c.Where); err != nil {
+		return fmt.Errorf("field 'where' is too large: %w", err)
+	}
+
+	if c.UploadConcurrency < 0 {
+		return errors.New("field 'upload_concurrency' must not be negative")
+	}
+	if c.MaxUploadBytesPerSecond < 0 {
+		return errors.New("field 'max_upload_bytes_per_second' must not be negative")
+	}
+
+	if !validConflictPolicy(c.OnConflict) {
+		return fmt.Errorf("field 'on_conflict' must be %q, %q or %q", ConflictOverwrite, ConflictSkip, ConflictError)
+	}
+
+	if c.TrashRetentionDays < 0 {
+		return errors.New("field 'trash_retention_days' must not be negative")
+	}
+
+	if c.DailyDeployLimit < 0 {
+		return errors.New("field 'daily_deploy_limit' must not be negative")
+	}
+	switch c.OnBudgetExceeded {
+	case "", BudgetRefuse, BudgetQueue:
+	default:
+		return fmt.Errorf("field 'on_budget_exceeded' must be %q or %q", BudgetRefuse, BudgetQueue)
+	}
+
 	if c.Headers != nil {
 		if len(c.Headers)-3 > 40 { // subtracting 3 for the default headers we add
 			return errors.New("headers must contain at most 40 entries")
@@ -67,72 +116,155 @@ func (c *CFS3Config) validate() error {
 		}
 	}
 
+	stdinUsed := false
 	for i, fp := range c.FilesPatch {
-		if fp.LocalFile == "" {
+		if !validConflictPolicy(fp.OnConflict) {
+			return fmt.Errorf("files__patch[%d]: field 'on_conflict' must be %q, %q or %q", i, ConflictOverwrite, ConflictSkip, ConflictError)
+		}
+
+		switch fp.Type {
+		case "", SourceFile:
+		case SourceArchive:
+			if fp.Reader != nil || fp.FS != nil {
+				return fmt.Errorf("files__patch[%d]: archives must be read from 'local_file'", i)
+			}
+			if fp.RemotePath != "" {
+				return fmt.Errorf("files__patch[%d]: archives are extracted below 'remote_dir', 'remote_path' is not supported", i)
+			}
+		default:
+			return fmt.Errorf("files__patch[%d]: field 'type' must be %q or %q", i, SourceFile, SourceArchive)
+		}
+
+		switch {
+		case fp.Reader != nil && fp.FS != nil:
+			return fmt.Errorf("files__patch[%d]: Reader and FS are mutually exclusive", i)
+		case fp.Reader != nil:
+			if fp.Name == "" {
+				return fmt.Errorf("files__patch[%d]: field 'name' is required when patching from a reader", i)
+			}
+			if fp.Size < 0 {
+				return fmt.Errorf("files__patch[%d]: Size must not be negative", i)
+			}
+		case fp.LocalFile == "":
 			return fmt.Errorf("files__patch[%d]: field 'local_file' is required", i)
+		case fp.FS != nil && !fs.ValidPath(fp.LocalFile):
+			return fmt.Errorf("files__patch[%d]: field 'local_file' must be a valid path inside FS", i)
+		case fp.isStdin():
+			if fp.Name == "" {
+				return fmt.Errorf("files__patch[%d]: field 'name' is required when reading from stdin", i)
+			}
+			if stdinUsed {
+				return fmt.Errorf("files__patch[%d]: stdin can only be read once per run", i)
+			}
+			stdinUsed = true
+		}
+		if fp.Remote == "" && fp.RemotePath == "" {
+			return fmt.Errorf("files__patch[%d]: field 'remote_dir' or 'remote_path' is required", i)
 		}
-		if fp.Remote == "" {
-			return fmt.Errorf("files__patch[%d]: field 'remote_dir' is required", i)
+		if fp.RemotePath != "" && strings.Trim(path.Clean("/"+fp.RemotePath), "/") == "" {
+			return fmt.Errorf("files__patch[%d]: field 'remote_path' must name a file", i)
 		}
 
-		fileName := path.Base(fp.LocalFile)
+		fileName := fp.fileName()
 		if len(fileName) > 900 {
 			return fmt.Errorf("files__patch[%d]: local file name exceeds 900 character limit", i)
 		}
 	}
 
+	switch c.Mode {
+	case ModePatch:
+		return c.validatePatchMetadata()
+	case ModeMeta:
+		// The updated metadata depends on what is stored, it is checked by applyMeta.
+		_, err := c.metadataSchema()
+		return err
+	}
 	return nil
 }
 
-// processPatchFiles processes the patch files, generating SHA1 hashes and copying them to the specified directory.
-// It also ensures the directory structure is created as needed.
-func (c *CFS3Config) processPatchFiles(parentDir string) (map[string]string, error) {
+// processPatchFiles generates SHA1 hashes of the patch files and derives their remote paths.
+// Files are not copied anywhere: the source of each remote file is recorded for Deploy.
+func (c *CFS3Config) processPatchFiles() (map[string]string, error) {
 	if c.Mode != ModePatch {
 		return nil, nil // No-op for non-patch mode
 	}
 
-	fileNameMap := make(map[string]string)
+	// Resolve every entry to local content first: stdin and URLs are spooled and
+	// archives are expanded into one patch per entry.
+	patches := make([]FilePatch, 0, len(c.FilesPatch))
 	for i, fp := range c.FilesPatch {
-		f, err := os.Open(fp.LocalFile)
-		if err != nil {
-			return nil, fmt.Errorf("opening %q: %w", fp.LocalFile, err)
+		if fp.isStdin() || fp.isURL() {
+			spooled, err := c.spoolSource(i, fp)
+			if err != nil {
+				return nil, fmt.Errorf("reading %q: %w", fp.LocalFile, err)
+			}
+			fp = spooled
 		}
-		defer f.Close()
 
-		hasher := sha1.New()
-		if _, err := io.Copy(hasher, f); err != nil {
-			return nil, fmt.Errorf("hashing %q: %w", fp.LocalFile, err)
+		if fp.Type == SourceArchive {
+			entries, err := c.expandArchive(i, fp)
+			if err != nil {
+				return nil, fmt.Errorf("expanding archive %q: %w", fp.LocalFile, err)
+			}
+			patches = append(patches, entries...)
+		} else {
+			patches = append(patches, fp)
 		}
-		sha1hex := hex.EncodeToString(hasher.Sum(nil))
-		ext := strings.TrimPrefix(filepath.Ext(fp.LocalFile), ".")
 
-		fp.Remote = path.Clean(fp.Remote)
-		fp.Remote = strings.TrimPrefix(fp.Remote, "/")
-		fp.Remote = path.Join(fp.Remote, fmt.Sprintf("%s.%s", sha1hex, ext))
-		fp.Remote = filepath.ToSlash(fp.Remote)
-
-		fileNameMap[fp.Remote] = filepath.Base(fp.LocalFile)
+		if len(patches) > vars.MAX_ASSET_COUNT {
+			return nil, fmt.Errorf("number of files exceeds maximum allowed %d", vars.MAX_ASSET_COUNT)
+		}
+	}
 
-		dest := path.Join(parentDir, fp.Remote)
-		if err := os.MkdirAll(path.Dir(dest), 0o755); err != nil {
-			return nil, fmt.Errorf("making dirs for %q: %w", dest, err)
+	c.files = make(map[string]string, len(patches))
+	c.assets = make(map[string]types.Asset)
+	c.hashes = make(map[string]string, len(patches))
+	fileNameMap := make(map[string]string)
+	for i, fp := range patches {
+		hashes, asset, err := fp.source()
+		if err != nil {
+			return nil, fmt.Errorf("hashing %q: %w", fp.fileName(), err)
+		}
+		sha1hex := hashes.SHA1
+		ext := fp.extension()
+
+		if fp.RemotePath != "" {
+			fp.Remote = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(fp.RemotePath)), "/")
+		} else {
+			fp.Remote = path.Clean(fp.Remote)
+			fp.Remote = strings.TrimPrefix(fp.Remote, "/")
+			fp.Remote = path.Join(fp.Remote, fmt.Sprintf("%s.%s", sha1hex, ext))
+			fp.Remote = filepath.ToSlash(fp.Remote)
 		}
 
-		// Copy file content a second time from start
-		if _, err := f.Seek(0, io.SeekStart); err != nil {
-			return nil, fmt.Errorf("rewinding %q: %w", fp.LocalFile, err)
+		// Pages hashes content with the remote extension, the source hash only holds if it matches.
+		hash := hashes.Blake3
+		if remoteExt := strings.TrimPrefix(path.Ext(fp.Remote), "."); remoteExt != ext {
+			if hash, err = fp.assetHash(asset, remoteExt); err != nil {
+				return nil, fmt.Errorf("hashing %q: %w", fp.fileName(), err)
+			}
 		}
-		out, err := os.Create(dest)
-		if err != nil {
-			return nil, fmt.Errorf("creating %q: %w", dest, err)
+		if asset != nil {
+			asset.Hash = hash
+		}
+		c.hashes[fp.Remote] = hash
+
+		// Archive entries are site files served inline, they don't get a download name.
+		if fp.extracted == nil {
+			fileNameMap[fp.Remote] = fp.fileName()
 		}
-		if _, err := io.Copy(out, f); err != nil {
-			out.Close()
-			return nil, fmt.Errorf("copying to %q: %w", dest, err)
+		if asset != nil {
+			c.assets[fp.Remote] = *asset
+		} else {
+			c.files[fp.Remote] = fp.LocalFile
 		}
-		out.Close()
 
-		c.FilesPatch[i] = fp
+		patches[i] = fp
+	}
+	c.FilesPatch = patches
+
+	if err := utils.SaveHashCache(); err != nil {
+		fmt.Println("⚠️ Failed to save hash cache:", err)
 	}
 
 	return fileNameMap, nil
@@ -181,28 +313,27 @@ func (c *CFS3Config) createHeadersFile(parentDir string, fileMap map[string]stri
 	return nil
 }
 
-func (c *CFS3Config) upsertMetadata() error {
+// upsertMetadata records the outcome of a deployment in D1 and returns the IDs of the object
+// versions it wrote.
+func (c *CFS3Config) upsertMetadata() ([]int64, error) {
 	switch c.Mode {
 	case ModePatch:
-		objects := buildObjects(c.metadata, utils.Clone(c.metadata), c.FilesPatch, c.By, c.ProjectName)
+		objects := buildObjects(c.metadata, c.statuses, c.FilesPatch, c.By, c.ProjectName)
 		return worker.BulkAddObjects(objects)
 	case ModeRemove:
 		return worker.BulkRemoveObjects(c.FilesRemove)
 	}
 
-	return nil
+	return nil, nil
 }
 
-func buildObjects(all, existing map[string]types.FileContainer, filePatches []FilePatch, by, projName string) []worker.Object {
-	objects := make([]worker.Object, 0, len(all)-len(existing))
+// buildObjects returns the objects to write for the patched files, leaving out skipped ones.
+func buildObjects(all map[string]types.FileContainer, statuses map[string]FileStatus, filePatches []FilePatch, by, projName string) []worker.Object {
+	objects := make([]worker.Object, 0, len(filePatches))
 
 	for _, file := range filePatches {
-		if _, exists := existing[file.Remote]; exists {
-			continue
-		}
-
 		fileContainer, exists := all[file.Remote]
-		if !exists {
+		if !exists || statuses[file.Remote] == FileSkipped {
 			continue
 		}
 
@@ -217,10 +348,11 @@ func buildObjects(all, existing map[string]types.FileContainer, filePatches []Fi
 		objects = append(objects, worker.Object{
 			Hash:        fileContainer.Hash,
 			RelPath:     file.Remote,
-			Name:        path.Base(file.LocalFile),
+			Name:        file.fileName(),
 			AddedBy:     &by,
 			ProjectName: projName,
 			Metadata:    &metaJson,
+			Size:        fileContainer.SizeInBytes,
 		})
 	}
 
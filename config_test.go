@@ -0,0 +1,39 @@
+package cfs3
+
+import (
+	"testing"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+func TestValidateWhereParams(t *testing.T) {
+	in := func(n int) worker.MetaPredicate {
+		values := make([]any, n)
+		for i := range values {
+			values[i] = float64(i)
+		}
+		return worker.MetaPredicate{Key: "n", Op: worker.MetaIn, Values: values}
+	}
+	gt := worker.MetaPredicate{Key: "size", Op: worker.MetaGt, Value: float64(1)}
+
+	tests := []struct {
+		name    string
+		where   []worker.MetaPredicate
+		wantErr bool
+	}{
+		{"single in", []worker.MetaPredicate{in(45)}, false},                    // 3 + 46
+		{"at the limit", []worker.MetaPredicate{in(45), in(40)}, false},         // 3 + 46 + 41 = 90
+		{"over the limit", []worker.MetaPredicate{in(45), in(41)}, true},        // 3 + 46 + 42
+		{"with a comparison", []worker.MetaPredicate{in(45), in(39), gt}, true}, // 3 + 46 + 40 + 3
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &CFS3Config{By: "test", Mode: ModeList, ProjectName: "p", Where: tt.where}
+			err := c.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package cfs3
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// ConflictPolicy decides what a patch does to a path that already holds an object.
+type ConflictPolicy string
+
+const (
+	ConflictOverwrite ConflictPolicy = "overwrite" // replace content, metadata and uploader (default)
+	ConflictSkip      ConflictPolicy = "skip"      // keep the existing object untouched
+	ConflictError     ConflictPolicy = "error"     // fail the run before anything is deployed
+)
+
+// FileStatus is what a patch did to its remote path.
+type FileStatus string
+
+const (
+	FileCreated FileStatus = "created"
+	FileUpdated FileStatus = "updated"
+	FileSkipped FileStatus = "skipped" // kept by the skip policy, or already identical
+)
+
+// FileReport is the outcome of one patched file.
+type FileReport struct {
+	RelPath string     `json:"rel_path"`
+	Status  FileStatus `json:"status"`
+}
+
+// conflictPolicy returns the policy of the patch, falling back to the config's.
+func (fp FilePatch) conflictPolicy(fallback ConflictPolicy) ConflictPolicy {
+	if fp.OnConflict != "" {
+		return fp.OnConflict
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return ConflictOverwrite
+}
+
+func validConflictPolicy(p ConflictPolicy) bool {
+	switch p {
+	case "", ConflictOverwrite, ConflictSkip, ConflictError:
+		return true
+	}
+	return false
+}
+
+// resolveConflicts applies the conflict policy of every patch whose remote path already holds
+// an object. Files overwriting an object with the very same content, metadata and uploader are
+// skipped as well, they would only cost a deployment. Skipped files are dropped from the upload
+// and from fileNameMap; they stay in FilesPatch so results can still be reported per file.
+func (c *CFS3Config) resolveConflicts(fileNameMap map[string]string) error {
+	remotes := make([]string, len(c.FilesPatch))
+	for i, fp := range c.FilesPatch {
+		remotes[i] = fp.Remote
+	}
+	previous, err := worker.FetchObjectsByPaths(c.ProjectName, remotes)
+	if err != nil {
+		return fmt.Errorf("failure fetching existing objects: %w", err)
+	}
+
+	c.previous = make(map[string]worker.Object, len(previous))
+	for _, obj := range previous {
+		c.previous[obj.RelPath] = obj
+	}
+
+	c.statuses = make(map[string]FileStatus, len(c.FilesPatch))
+	for _, fp := range c.FilesPatch {
+		prev, exists := c.previous[fp.Remote]
+		if !exists {
+			c.statuses[fp.Remote] = FileCreated
+			continue
+		}
+
+		policy := fp.conflictPolicy(c.OnConflict)
+		switch {
+		case policy == ConflictError:
+			return fmt.Errorf("%s: remote path %s already exists (on_conflict is %q)", fp.fileName(), fp.Remote, ConflictError)
+		case policy == ConflictSkip, unchanged(prev, c.hashes[fp.Remote], fp.Metadata, c.By):
+			c.statuses[fp.Remote] = FileSkipped
+			delete(c.files, fp.Remote)
+			delete(c.assets, fp.Remote)
+			delete(fileNameMap, fp.Remote)
+		default:
+			c.statuses[fp.Remote] = FileUpdated
+		}
+	}
+
+	return nil
+}
+
+// fileReports lists the status of every patched file, in patch order.
+func (c *CFS3Config) fileReports() []FileReport {
+	reports := make([]FileReport, 0, len(c.FilesPatch))
+	for _, fp := range c.FilesPatch {
+		reports = append(reports, FileReport{RelPath: fp.Remote, Status: c.statuses[fp.Remote]})
+	}
+	return reports
+}
+
+func printFileReports(reports []FileReport) {
+	for _, r := range reports {
+		fmt.Printf("  %s\t%s\n", r.Status, r.RelPath)
+	}
+}
+
+// unchanged reports whether obj already holds exactly what a patch would write.
+func unchanged(obj worker.Object, hash string, metadata map[string]any, by string) bool {
+	if obj.Hash != hash || obj.AddedBy == nil || *obj.AddedBy != by {
+		return false
+	}
+
+	// Compare in the re-encoded form, key order and spacing of the stored JSON don't count.
+	patched, err := json.Marshal(metadata)
+	if err != nil {
+		return false
+	}
+	patchedJSON := string(patched)
+	before, errBefore := MetaUpdate{}.apply(obj.Metadata)
+	after, errAfter := MetaUpdate{}.apply(&patchedJSON)
+	return errBefore == nil && errAfter == nil && *before == *after
+}
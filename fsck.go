@@ -0,0 +1,175 @@
+package cfs3
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/Hack-Nocturne/cfs3/types"
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/vars"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// modeRedeploy is recorded for deployments made by Redeploy.
+const modeRedeploy CFS3Mode = "redeploy"
+
+// FsckReport lists the differences between D1 and the live deployment of a project.
+type FsckReport struct {
+	ProjectName  string   `json:"project_name"`
+	DeploymentID string   `json:"deployment_id"`
+	Missing      []string `json:"missing"`    // recorded in D1 but absent from the deployment
+	Extra        []string `json:"extra"`      // deployed but not recorded in D1
+	Mismatched   []string `json:"mismatched"` // recorded with a different hash than deployed
+
+	deploymentURL string
+	manifest      map[string]string
+}
+
+// Clean reports whether D1 and the deployment agree.
+func (r *FsckReport) Clean() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// Fsck compares the objects recorded in D1 with the manifest of the project's latest
+// production deployment.
+func (c *CFS3Config) Fsck() (*FsckReport, error) {
+	deployment, err := utils.FetchLatestDeployment(vars.CF_ACCOUNT_ID, c.ProjectName)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching latest deployment: %w", err)
+	}
+
+	manifest, err := utils.FetchDeploymentManifest(vars.CF_ACCOUNT_ID, c.ProjectName, deployment.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching deployment manifest: %w", err)
+	}
+
+	meta, err := worker.FetchAllMeta(c.ProjectName)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching existing meta: %w", err)
+	}
+
+	report := &FsckReport{ProjectName: c.ProjectName, DeploymentID: deployment.ID, deploymentURL: deployment.URL, manifest: manifest}
+	for relPath, container := range meta {
+		hash, deployed := manifest[relPath]
+		switch {
+		case !deployed:
+			report.Missing = append(report.Missing, relPath)
+		case hash != container.Hash:
+			report.Mismatched = append(report.Mismatched, relPath)
+		}
+	}
+	for relPath := range manifest {
+		if _, recorded := meta[relPath]; !recorded {
+			report.Extra = append(report.Extra, relPath)
+		}
+	}
+
+	slices.Sort(report.Missing)
+	slices.Sort(report.Extra)
+	slices.Sort(report.Mismatched)
+
+	return report, nil
+}
+
+// RepairD1 makes D1 match the deployment the report was made against: missing objects are
+// deleted, extra files are recorded (with c.By as uploader) and mismatched hashes are
+// replaced by the deployed ones. The sizes of recorded and changed objects are asked from
+// the deployment.
+func (c *CFS3Config) RepairD1(report *FsckReport) error {
+	if len(report.Missing) > 0 {
+		objects, err := worker.FetchObjectsByPaths(c.ProjectName, report.Missing)
+		if err != nil {
+			return fmt.Errorf("failure fetching missing objects: %w", err)
+		}
+
+		ids := make([]int64, len(objects))
+		for i, obj := range objects {
+			ids[i] = obj.ID
+		}
+		if _, err := worker.BulkRemoveObjects(ids); err != nil {
+			return fmt.Errorf("failure removing missing objects: %w", err)
+		}
+	}
+
+	mismatched, err := worker.FetchObjectsByPaths(c.ProjectName, report.Mismatched)
+	if err != nil {
+		return fmt.Errorf("failure fetching mismatched objects: %w", err)
+	}
+
+	if len(mismatched) == 0 && len(report.Extra) == 0 {
+		return nil
+	}
+
+	sizes, err := fetchSizes(report.deploymentURL, append(slices.Clone(report.Mismatched), report.Extra...))
+	if err != nil {
+		return fmt.Errorf("failure fetching file sizes: %w", err)
+	}
+
+	objects := make([]worker.Object, 0, len(mismatched)+len(report.Extra))
+	for _, obj := range mismatched {
+		obj.Hash = report.manifest[obj.RelPath]
+		obj.Size = sizes[obj.RelPath]
+		objects = append(objects, obj)
+	}
+	objects = append(objects, c.manifestObjects(report.manifest, sizes, report.Extra)...)
+
+	_, err = worker.BulkAddObjects(objects)
+	return err
+}
+
+// Redeploy deploys exactly the file set recorded in D1, overwriting whatever the live
+// deployment contains. Nothing is uploaded; the redeploy fails with utils.ErrAssetUnavailable
+// if Cloudflare no longer holds an asset, because cfs3 keeps no local copies.
+func (c *CFS3Config) Redeploy() (*types.DeploymentResponse, error) {
+	meta, err := worker.FetchAllMeta(c.ProjectName)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching existing meta: %w", err)
+	}
+
+	deployResp, err := c.deployHashes(modeRedeploy, meta, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.recordDeploymentObjects(deployResp.ID, nil, nil, nil)
+	return deployResp, nil
+}
+
+// deployHashes deploys a file set made only of assets Cloudflare already holds, referenced by
+// hash. fileNames adds download names for the given paths to _headers. The deployment goes
+// through the budget check and is recorded under mode.
+func (c *CFS3Config) deployHashes(mode CFS3Mode, files map[string]types.FileContainer, fileNames map[string]string) (*types.DeploymentResponse, error) {
+	if err := c.checkDeployBudget(); err != nil {
+		return nil, err
+	}
+
+	stagingDir, err := os.MkdirTemp("", vars.STAGING_DIR_PATTERN)
+	if err != nil {
+		return nil, fmt.Errorf("error creating staging dir: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	c.addDefaultHeaders()
+	if err := c.createHeadersFile(stagingDir, fileNames); err != nil {
+		return nil, fmt.Errorf("error creating headers file: %w", err)
+	}
+
+	deployResp, _, err := utils.Deploy(types.PagesDeployOptions{
+		Directory:   stagingDir,
+		AccountId:   vars.CF_ACCOUNT_ID,
+		ProjectName: c.ProjectName,
+		Files:       map[string]string{}, // nothing new, only assets referenced by hash
+		Existing:    files,
+
+		Concurrency:       c.UploadConcurrency,
+		MaxBytesPerSecond: c.MaxUploadBytesPerSecond,
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("💫 Deployment completed with ID: " + deployResp.ID)
+	c.recordDeployment(mode, deployResp.ID, deployResp.URL)
+	return deployResp, nil
+}
@@ -4,9 +4,12 @@ go 1.23.2
 
 require (
 	github.com/bmatcuk/doublestar/v4 v4.8.1
+	github.com/dlclark/regexp2 v1.11.0
 	github.com/joho/godotenv v1.5.1
 	github.com/kofj/gorm-driver-d1 v1.0.0-rc1
+	github.com/santhosh-tekuri/jsonschema/v6 v6.0.2
 	github.com/zeebo/blake3 v0.2.4
+	golang.org/x/text v0.20.0
 	gorm.io/gorm v1.26.0
 )
 
@@ -14,5 +17,4 @@ require (
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/klauspost/cpuid/v2 v2.0.12 // indirect
-	golang.org/x/text v0.20.0 // indirect
 )
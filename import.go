@@ -0,0 +1,156 @@
+package cfs3
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Hack-Nocturne/cfs3/types"
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/vars"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// ImportResult describes the outcome of Import.
+type ImportResult struct {
+	DeploymentID string `json:"deployment_id"`
+	Imported     int    `json:"imported"`
+	Skipped      int    `json:"skipped"` // paths already recorded in D1
+}
+
+// Import records the files of an existing deployment (e.g. one made with wrangler) as
+// objects, so later patches keep them. deploymentId defaults to the latest production
+// deployment. Paths already recorded in D1 are left alone; use Fsck to compare their hashes.
+func (c *CFS3Config) Import(deploymentId string) (*ImportResult, error) {
+	var deployment *types.DeploymentResponse
+	var err error
+	if deploymentId == "" {
+		deployment, err = utils.FetchLatestDeployment(vars.CF_ACCOUNT_ID, c.ProjectName)
+		if err != nil {
+			return nil, fmt.Errorf("failure fetching latest deployment: %w", err)
+		}
+		deploymentId = deployment.ID
+	} else {
+		deployment, err = utils.FetchDeployment(vars.CF_ACCOUNT_ID, c.ProjectName, deploymentId)
+		if err != nil {
+			return nil, fmt.Errorf("failure fetching deployment: %w", err)
+		}
+	}
+
+	manifest, err := utils.FetchDeploymentManifest(vars.CF_ACCOUNT_ID, c.ProjectName, deploymentId)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching deployment manifest: %w", err)
+	}
+
+	meta, err := worker.FetchAllMeta(c.ProjectName)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching existing meta: %w", err)
+	}
+
+	var paths []string
+	for relPath := range manifest {
+		if _, recorded := meta[relPath]; !recorded {
+			paths = append(paths, relPath)
+		}
+	}
+	slices.Sort(paths)
+
+	if len(paths) > 0 {
+		sizes, err := fetchSizes(deployment.URL, paths)
+		if err != nil {
+			return nil, fmt.Errorf("failure fetching file sizes: %w", err)
+		}
+		if _, err := worker.BulkAddObjects(c.manifestObjects(manifest, sizes, paths)); err != nil {
+			return nil, fmt.Errorf("failure recording objects: %w", err)
+		}
+	}
+
+	return &ImportResult{
+		DeploymentID: deploymentId,
+		Imported:     len(paths),
+		Skipped:      len(manifest) - len(paths),
+	}, nil
+}
+
+// manifestObjects builds objects for the given manifest paths, added by c.By.
+func (c *CFS3Config) manifestObjects(manifest map[string]string, sizes map[string]int64, paths []string) []worker.Object {
+	objects := make([]worker.Object, 0, len(paths))
+	for _, relPath := range paths {
+		by := c.By
+		objects = append(objects, worker.Object{
+			Hash:        manifest[relPath],
+			RelPath:     relPath,
+			Name:        path.Base(relPath),
+			Size:        sizes[relPath],
+			AddedBy:     &by,
+			ProjectName: c.ProjectName,
+		})
+	}
+
+	return objects
+}
+
+// fetchSizes asks the deployment at baseURL for the size of every path with a HEAD request,
+// PULL_CONCURRENCY at a time. Manifests only list hashes, and a size that is merely unknown
+// must not be recorded as 0.
+func fetchSizes(baseURL string, paths []string) (map[string]int64, error) {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		sizes = make(map[string]int64, len(paths))
+		errs  []error
+	)
+	client := &http.Client{Timeout: vars.SOURCE_FETCH_TIMEOUT}
+	tasks := make(chan string)
+
+	for range min(vars.PULL_CONCURRENCY, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for relPath := range tasks {
+				size, err := headSize(client, strings.TrimSuffix(baseURL, "/")+(&url.URL{Path: "/" + relPath}).EscapedPath())
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", relPath, err))
+				} else {
+					sizes[relPath] = size
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, relPath := range paths {
+		tasks <- relPath
+	}
+	close(tasks)
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// headSize returns the Content-Length of the file at url.
+func headSize(client *http.Client, url string) (int64, error) {
+	resp, err := client.Head(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return 0, errors.New("unknown content length")
+	}
+	return resp.ContentLength, nil
+}
@@ -0,0 +1,30 @@
+package cfs3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFetchSizes(t *testing.T) {
+	srv := serveSite(t, map[string]string{
+		"index.html":      "<h1>Hello</h1>",
+		"img/a b.png":     strings.Repeat("x", 1234),
+		"empty/file.json": "",
+	})
+
+	sizes, err := fetchSizes(srv.URL, []string{"index.html", "img/a b.png", "empty/file.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"index.html": 14, "img/a b.png": 1234, "empty/file.json": 0}
+	for relPath, size := range want {
+		if got, ok := sizes[relPath]; !ok || got != size {
+			t.Errorf("size of %s = %d (found %v), want %d", relPath, got, ok, size)
+		}
+	}
+
+	// A path the deployment doesn't serve fails instead of being recorded without a size.
+	if _, err := fetchSizes(srv.URL, []string{"index.html", "missing.txt"}); err == nil {
+		t.Error("fetchSizes succeeded for a missing file")
+	}
+}
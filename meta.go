@@ -0,0 +1,204 @@
+package cfs3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// MetaUpdate changes the metadata of the objects it selects by exactly one of IDs, Path or
+// Prefix. Set overwrites top-level keys, Merge merges nested objects key by key, and Delete
+// removes top-level keys; they are applied in that order.
+type MetaUpdate struct {
+	IDs    []int64 `json:"ids,omitempty"`
+	Path   string  `json:"path,omitempty"`
+	Prefix string  `json:"prefix,omitempty"`
+
+	Set    map[string]any `json:"set,omitempty"`
+	Merge  map[string]any `json:"merge,omitempty"`
+	Delete []string       `json:"delete,omitempty"`
+}
+
+func (u MetaUpdate) validate() error {
+	selectors := 0
+	if len(u.IDs) > 0 {
+		selectors++
+	}
+	if u.Path != "" {
+		selectors++
+	}
+	if u.Prefix != "" {
+		selectors++
+	}
+	if selectors != 1 {
+		return errors.New("exactly one of 'ids', 'path' or 'prefix' is required")
+	}
+
+	if len(u.Set) == 0 && len(u.Merge) == 0 && len(u.Delete) == 0 {
+		return errors.New("one of 'set', 'merge' or 'delete' is required")
+	}
+	if slices.Contains(u.Delete, "") {
+		return errors.New("'delete' keys must be non-empty")
+	}
+
+	return nil
+}
+
+// selectObjects returns the objects of the project the update applies to.
+func (u MetaUpdate) selectObjects(projName string) ([]worker.Object, error) {
+	switch {
+	case len(u.IDs) > 0:
+		objects, err := worker.FetchObjectsByIDs(projName, u.IDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(objects) != len(u.IDs) {
+			return nil, fmt.Errorf("%d of the %d ids are not objects of %s", len(u.IDs)-len(objects), len(u.IDs), projName)
+		}
+		return objects, nil
+	case u.Path != "":
+		relPath := strings.Trim(path.Clean("/"+u.Path), "/")
+		objects, err := worker.FetchObjectsByPaths(projName, []string{relPath})
+		if err != nil {
+			return nil, err
+		}
+		if len(objects) == 0 {
+			return nil, fmt.Errorf("no object at %s in %s", relPath, projName)
+		}
+		return objects, nil
+	default:
+		return worker.FetchObjectsByPrefix(projName, strings.TrimPrefix(u.Prefix, "/"))
+	}
+}
+
+// apply returns metadata with the update applied.
+func (u MetaUpdate) apply(metadata *string) (*string, error) {
+	fields := make(map[string]any)
+	if metadata != nil && *metadata != "null" {
+		// Keep numbers as written, large integers would lose precision as float64.
+		dec := json.NewDecoder(strings.NewReader(*metadata))
+		dec.UseNumber()
+		if err := dec.Decode(&fields); err != nil {
+			return nil, fmt.Errorf("existing metadata is not a JSON object: %w", err)
+		}
+		if fields == nil {
+			fields = make(map[string]any)
+		}
+	}
+
+	// Values are copied, merging into them must not change the update for the next object.
+	for k, v := range u.Set {
+		fields[k] = cloneValue(v)
+	}
+	mergeFields(fields, u.Merge)
+	for _, k := range u.Delete {
+		delete(fields, k)
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	updated := string(b)
+	return &updated, nil
+}
+
+// mergeFields merges src into dst: objects present in both are merged recursively, any other
+// value in src replaces the one in dst.
+func mergeFields(dst, src map[string]any) {
+	for k, v := range src {
+		srcObj, srcIsObj := v.(map[string]any)
+		dstObj, dstIsObj := dst[k].(map[string]any)
+		if srcIsObj && dstIsObj {
+			mergeFields(dstObj, srcObj)
+			continue
+		}
+		dst[k] = cloneValue(v)
+	}
+}
+
+// cloneValue deep-copies the objects and arrays of a decoded JSON value.
+func cloneValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		clone := make(map[string]any, len(v))
+		for k, elem := range v {
+			clone[k] = cloneValue(elem)
+		}
+		return clone
+	case []any:
+		clone := make([]any, len(v))
+		for i, elem := range v {
+			clone[i] = cloneValue(elem)
+		}
+		return clone
+	default:
+		return v
+	}
+}
+
+// applyMeta runs the metadata updates of a meta-mode config. Objects whose metadata ends up
+// unchanged aren't written.
+func (c *CFS3Config) applyMeta() error {
+	updated := make(map[int64]worker.Object)
+	original := make(map[int64]*string)
+	var order []int64
+
+	for i, u := range c.FilesMeta {
+		objects, err := u.selectObjects(c.ProjectName)
+		if err != nil {
+			return fmt.Errorf("files__meta[%d]: failure selecting objects: %w", i, err)
+		}
+
+		for _, obj := range objects {
+			// Later updates build on earlier ones selecting the same object.
+			if prev, ok := updated[obj.ID]; ok {
+				obj = prev
+			} else {
+				original[obj.ID] = obj.Metadata
+				order = append(order, obj.ID)
+			}
+
+			metadata, err := u.apply(obj.Metadata)
+			if err != nil {
+				return fmt.Errorf("files__meta[%d]: %s: %w", i, obj.RelPath, err)
+			}
+			obj.Metadata = metadata
+			updated[obj.ID] = obj
+		}
+	}
+
+	var changed []worker.Object
+	for _, id := range order {
+		// Compare in the re-encoded form, key order and spacing of the stored JSON don't count.
+		before, _ := MetaUpdate{}.apply(original[id])
+		if !sameMetadata(before, updated[id].Metadata) {
+			changed = append(changed, updated[id])
+		}
+	}
+
+	schema, err := c.metadataSchema()
+	if err != nil {
+		return err
+	}
+	if schema != nil {
+		for _, obj := range changed {
+			if err := validateObjectMetadata(schema, obj.Metadata); err != nil {
+				return fmt.Errorf("files__meta: %s: invalid metadata: %w", obj.RelPath, err)
+			}
+		}
+	}
+
+	if _, err := worker.UpdateObjectsMetadata(c.ProjectName, changed); err != nil {
+		return fmt.Errorf("failure updating metadata: %w", err)
+	}
+
+	c.result = &ApplyResult{Objects: changed}
+	fmt.Printf("📝 Updated the metadata of %d object(s), no deployment needed\n", len(changed))
+	return nil
+}
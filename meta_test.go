@@ -0,0 +1,43 @@
+package cfs3
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMetaUpdateApplyDoesNotShareValues(t *testing.T) {
+	var u MetaUpdate
+	err := json.Unmarshal([]byte(`{
+		"ids": [1, 2],
+		"set": {"owner": {"team": "web"}, "tags": ["a"]},
+		"merge": {"owner": {"lead": "sam"}, "extra": {"n": 1}}
+	}`), &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := json.Marshal(u)
+
+	existing := []string{`{"extra": {"m": 2}}`, `{"extra": {"k": 3}}`}
+	want := []string{
+		`{"extra":{"m":2,"n":1},"owner":{"lead":"sam","team":"web"},"tags":["a"]}`,
+		`{"extra":{"k":3,"n":1},"owner":{"lead":"sam","team":"web"},"tags":["a"]}`,
+	}
+	for i, metadata := range existing {
+		got, err := u.apply(&metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var gotFields, wantFields map[string]any
+		json.Unmarshal([]byte(*got), &gotFields)
+		json.Unmarshal([]byte(want[i]), &wantFields)
+		if !reflect.DeepEqual(gotFields, wantFields) {
+			t.Errorf("apply(%s) = %s, want %s", metadata, *got, want[i])
+		}
+	}
+
+	if after, _ := json.Marshal(u); string(after) != string(before) {
+		t.Errorf("apply changed the update:\nbefore %s\nafter  %s", before, after)
+	}
+}
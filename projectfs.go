@@ -0,0 +1,354 @@
+package cfs3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/vars"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// ProjectFS is a read-only fs.FS view of a project. Directory listings and stat info come
+// from a snapshot of the project's D1 objects taken by NewProjectFS; file contents are
+// fetched from the deployed Pages site on demand.
+//
+// It implements fs.FS, fs.ReadDirFS and fs.StatFS, and its files are seekable, so it works
+// with http.FileServer(http.FS(...)), fs.WalkDir and template loaders. Stat asks the site
+// for the size of objects recorded without one.
+type ProjectFS struct {
+	baseURL string
+	client  *http.Client
+	files   map[string]worker.Object
+	dirs    map[string][]fs.DirEntry
+
+	mu    sync.Mutex
+	sizes map[string]int64 // sizes fetched for objects recorded without one, by name
+}
+
+var (
+	_ fs.ReadDirFS = (*ProjectFS)(nil)
+	_ fs.StatFS    = (*ProjectFS)(nil)
+)
+
+// NewProjectFS loads the objects of a project and returns a read-only file system over them.
+func NewProjectFS(projName string) (*ProjectFS, error) {
+	baseURL, err := utils.ProjectURL(vars.CF_ACCOUNT_ID, projName)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := worker.FetchObjectsByPrefix(projName, "")
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching objects: %w", err)
+	}
+
+	return newProjectFS(baseURL, objects), nil
+}
+
+func newProjectFS(baseURL string, objects []worker.Object) *ProjectFS {
+	pfs := &ProjectFS{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{},
+		files:   make(map[string]worker.Object, len(objects)),
+		dirs:    map[string][]fs.DirEntry{".": nil},
+		sizes:   make(map[string]int64),
+	}
+
+	seenDirs := map[string]bool{".": true}
+	for _, obj := range objects {
+		name := strings.Trim(path.Clean("/"+obj.RelPath), "/")
+		if name == "" {
+			continue
+		}
+		pfs.files[name] = obj
+
+		// Register the file in its parent and every missing ancestor directory in theirs.
+		var entry fs.DirEntry = fileEntry{pfs: pfs, name: name, obj: obj}
+		dir := path.Dir(name)
+		for {
+			pfs.dirs[dir] = append(pfs.dirs[dir], entry)
+			if seenDirs[dir] {
+				break
+			}
+			seenDirs[dir] = true
+			entry = fs.FileInfoToDirEntry(dirInfo{name: path.Base(dir)})
+			dir = path.Dir(dir)
+		}
+	}
+
+	for dir := range pfs.dirs {
+		slices.SortFunc(pfs.dirs[dir], func(a, b fs.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+	}
+
+	return pfs
+}
+
+// Open opens the named file or directory.
+func (p *ProjectFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if obj, ok := p.files[name]; ok {
+		return p.openFile(name, obj), nil
+	}
+	if entries, ok := p.dirs[name]; ok {
+		return &projectDir{info: dirInfo{name: path.Base(name)}, entries: entries}, nil
+	}
+
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir lists the named directory, sorted by file name.
+func (p *ProjectFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entries, ok := p.dirs[name]
+	if !ok {
+		if _, isFile := p.files[name]; isFile {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return slices.Clone(entries), nil
+}
+
+// Stat describes the named file or directory without fetching any content.
+func (p *ProjectFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if obj, ok := p.files[name]; ok {
+		return p.openFile(name, obj).Stat()
+	}
+	if _, ok := p.dirs[name]; ok {
+		return dirInfo{name: path.Base(name)}, nil
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (p *ProjectFS) openFile(name string, obj worker.Object) *projectFile {
+	return &projectFile{pfs: p, name: name, info: fileInfo{name: path.Base(name), obj: obj, size: obj.Size}}
+}
+
+// fileEntry is the directory entry of a file, its Info reports the size like Stat does.
+type fileEntry struct {
+	pfs  *ProjectFS
+	name string
+	obj  worker.Object
+}
+
+func (e fileEntry) Name() string               { return path.Base(e.name) }
+func (e fileEntry) IsDir() bool                { return false }
+func (e fileEntry) Type() fs.FileMode          { return 0 }
+func (e fileEntry) Info() (fs.FileInfo, error) { return e.pfs.openFile(e.name, e.obj).Stat() }
+
+// fileInfo describes a file; Sys returns its worker.Object.
+type fileInfo struct {
+	name string
+	obj  worker.Object
+	size int64
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return 0o444 }
+func (fi fileInfo) ModTime() time.Time { return fi.obj.UpdatedAt }
+func (fi fileInfo) IsDir() bool        { return false }
+func (fi fileInfo) Sys() any           { return fi.obj }
+
+type dirInfo struct {
+	name string
+}
+
+func (di dirInfo) Name() string       { return di.name }
+func (di dirInfo) Size() int64        { return 0 }
+func (di dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o555 }
+func (di dirInfo) ModTime() time.Time { return time.Time{} }
+func (di dirInfo) IsDir() bool        { return true }
+func (di dirInfo) Sys() any           { return nil }
+
+type projectDir struct {
+	info    dirInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *projectDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *projectDir) Close() error               { return nil }
+
+func (d *projectDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *projectDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return slices.Clone(remaining), nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(remaining))
+	d.offset += n
+	return slices.Clone(remaining[:n]), nil
+}
+
+// projectFile streams the deployed file over HTTP. Seeking re-issues the request with a Range header.
+type projectFile struct {
+	pfs    *ProjectFS
+	name   string
+	info   fileInfo
+	body   io.ReadCloser
+	pos    int64 // position of the next byte body yields
+	offset int64 // position requested by the caller
+}
+
+func (f *projectFile) Stat() (fs.FileInfo, error) {
+	size, err := f.resolveSize()
+	if err != nil {
+		return nil, err
+	}
+
+	info := f.info
+	info.size = size
+	return info, nil
+}
+
+func (f *projectFile) url() string {
+	return f.pfs.baseURL + (&url.URL{Path: "/" + f.info.obj.RelPath}).EscapedPath()
+}
+
+func (f *projectFile) Read(p []byte) (int, error) {
+	if f.body == nil || f.pos != f.offset {
+		if err := f.request(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.body.Read(p)
+	f.pos += int64(n)
+	f.offset = f.pos
+	return n, err
+}
+
+// request (re)opens the body at the current offset.
+func (f *projectFile) request() error {
+	if f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, f.url(), nil)
+	if err != nil {
+		return err
+	}
+	if f.offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(f.offset, 10)+"-")
+	}
+
+	resp, err := f.pfs.client.Do(req)
+	if err != nil {
+		return &fs.PathError{Op: "read", Path: f.info.obj.RelPath, Err: err}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		f.body, f.pos = io.NopCloser(strings.NewReader("")), f.offset
+		return nil
+	case resp.StatusCode == http.StatusOK && f.offset > 0:
+		// Range was ignored, skip to the requested offset.
+		if _, err := io.CopyN(io.Discard, resp.Body, f.offset); err != nil && !errors.Is(err, io.EOF) {
+			resp.Body.Close()
+			return &fs.PathError{Op: "read", Path: f.info.obj.RelPath, Err: err}
+		}
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent:
+		resp.Body.Close()
+		return &fs.PathError{Op: "read", Path: f.info.obj.RelPath, Err: fmt.Errorf("unexpected HTTP status %s", resp.Status)}
+	}
+
+	f.body, f.pos = resp.Body, f.offset
+	return nil
+}
+
+func (f *projectFile) Seek(offset int64, whence int) (int64, error) {
+	var base int64
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		base = f.offset
+	case io.SeekEnd:
+		size, err := f.resolveSize()
+		if err != nil {
+			return 0, err
+		}
+		base = size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.info.obj.RelPath, Err: fs.ErrInvalid}
+	}
+
+	if base+offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.info.obj.RelPath, Err: fs.ErrInvalid}
+	}
+	f.offset = base + offset
+	return f.offset, nil
+}
+
+// resolveSize returns the file size, asking the site once for objects recorded without one.
+func (f *projectFile) resolveSize() (int64, error) {
+	if f.info.obj.Size > 0 {
+		return f.info.obj.Size, nil
+	}
+
+	f.pfs.mu.Lock()
+	size, ok := f.pfs.sizes[f.name]
+	f.pfs.mu.Unlock()
+	if ok {
+		return size, nil
+	}
+
+	resp, err := f.pfs.client.Head(f.url())
+	if err != nil {
+		return 0, &fs.PathError{Op: "stat", Path: f.info.obj.RelPath, Err: err}
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, &fs.PathError{Op: "stat", Path: f.info.obj.RelPath, Err: fmt.Errorf("unexpected HTTP status %s", resp.Status)}
+	}
+	if resp.ContentLength < 0 {
+		return 0, &fs.PathError{Op: "stat", Path: f.info.obj.RelPath, Err: errors.New("unknown content length")}
+	}
+
+	f.pfs.mu.Lock()
+	f.pfs.sizes[f.name] = resp.ContentLength
+	f.pfs.mu.Unlock()
+	return resp.ContentLength, nil
+}
+
+func (f *projectFile) Close() error {
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
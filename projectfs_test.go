@@ -0,0 +1,93 @@
+package cfs3
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// serveSite serves content keyed by path like a deployed Pages site, with Range and HEAD support.
+func serveSite(t *testing.T, content map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := content[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProjectFS(t *testing.T) {
+	content := map[string]string{
+		"index.html":        "<h1>Hello, world!</h1>\n",
+		"docs/guide.txt":    strings.Repeat("cfs3 ", 1000),
+		"docs/img/logo.png": "\x89PNG\r\n\x1a\n",
+		"empty.txt":         "",
+	}
+	srv := serveSite(t, content)
+
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	objects := []worker.Object{
+		{ID: 1, RelPath: "index.html", Size: int64(len(content["index.html"])), UpdatedAt: updated},
+		{ID: 2, RelPath: "docs/guide.txt", UpdatedAt: updated}, // recorded before sizes were tracked
+		{ID: 3, RelPath: "docs/img/logo.png", Size: int64(len(content["docs/img/logo.png"])), UpdatedAt: updated},
+		{ID: 4, RelPath: "empty.txt", UpdatedAt: updated},
+	}
+	pfs := newProjectFS(srv.URL, objects)
+
+	if err := fstest.TestFS(pfs, "index.html", "docs/guide.txt", "docs/img/logo.png", "empty.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProjectFSLegacySize(t *testing.T) {
+	body := strings.Repeat("x", 4096)
+	srv := serveSite(t, map[string]string{"a/b.txt": body})
+	pfs := newProjectFS(srv.URL, []worker.Object{{ID: 1, RelPath: "a/b.txt"}})
+
+	info, err := fs.Stat(pfs, "a/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(body)) {
+		t.Errorf("Stat size = %d, want %d", info.Size(), len(body))
+	}
+
+	f, err := pfs.Open("a/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	info, err = f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(body)) {
+		t.Errorf("file Stat size = %d, want %d", info.Size(), len(body))
+	}
+
+	// Seeking from the end relies on the resolved size.
+	seeker := f.(io.Seeker)
+	if _, err := seeker.Seek(-10, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	tail, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tail, []byte(body[len(body)-10:])) {
+		t.Errorf("read %q after seeking to the end, want %q", tail, body[len(body)-10:])
+	}
+}
@@ -0,0 +1,179 @@
+package cfs3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/vars"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// PullOptions configures Pull.
+type PullOptions struct {
+	Prefix      string // only pull objects whose path starts with this prefix
+	Concurrency int    // parallel downloads, defaults to PULL_CONCURRENCY
+}
+
+// PullResult summarises a Pull.
+type PullResult struct {
+	Downloaded int   `json:"downloaded"`
+	Unchanged  int   `json:"unchanged"` // already present locally with the recorded hash
+	Bytes      int64 `json:"bytes"`
+}
+
+// objectSidecar is the metadata file written next to a pulled object.
+type objectSidecar struct {
+	worker.Object
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// Pull mirrors the objects of a project into destDir, keeping their remote paths. Every
+// file is downloaded from the deployed site, verified against its recorded hash and gets a
+// sidecar with its D1 row. Files that already match are not downloaded again.
+// All objects are attempted; the errors of the failed ones are returned together.
+func Pull(projName, destDir string, opts PullOptions) (*PullResult, error) {
+	baseURL, err := utils.ProjectURL(vars.CF_ACCOUNT_ID, projName)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := worker.FetchObjectsByPrefix(projName, strings.TrimPrefix(opts.Prefix, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching objects: %w", err)
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = vars.PULL_CONCURRENCY
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		result PullResult
+		errs   []error
+	)
+	client := &http.Client{}
+	tasks := make(chan worker.Object)
+
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range tasks {
+				size, downloaded, err := pullObject(client, baseURL, destDir, obj)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					errs = append(errs, fmt.Errorf("%s: %w", obj.RelPath, err))
+				case downloaded:
+					result.Downloaded++
+					result.Bytes += size
+				default:
+					result.Unchanged++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, obj := range objects {
+		tasks <- obj
+	}
+	close(tasks)
+	wg.Wait()
+
+	return &result, errors.Join(errs...)
+}
+
+// pullObject downloads one object unless the local copy already matches its hash, and
+// (re)writes its sidecar. It reports the number of bytes downloaded.
+func pullObject(client *http.Client, baseURL, destDir string, obj worker.Object) (int64, bool, error) {
+	rel := strings.Trim(path.Clean("/"+obj.RelPath), "/")
+	dest := filepath.Join(destDir, filepath.FromSlash(rel))
+	ext := strings.TrimPrefix(path.Ext(rel), ".")
+
+	downloaded := false
+	var size int64
+	if hash, err := utils.HashAssetFile(dest); err != nil || hash != obj.Hash {
+		if size, err = download(client, baseURL+(&url.URL{Path: "/" + rel}).EscapedPath(), dest, ext, obj.Hash); err != nil {
+			return 0, false, err
+		}
+		downloaded = true
+	}
+
+	return size, downloaded, writeSidecar(dest+vars.SIDECAR_SUFFIX, obj)
+}
+
+// download fetches url into dest through a temporary file that only replaces dest once the
+// content matches wantHash.
+func download(client *http.Client, url, dest, ext, wantHash string) (int64, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".cfs3-pull-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	counter := &countingWriter{w: tmp}
+	hash, err := utils.HashAsset(io.TeeReader(resp.Body, counter), ext)
+	if err != nil {
+		return 0, err
+	}
+	if hash != wantHash {
+		return 0, fmt.Errorf("hash mismatch: downloaded %s, recorded %s", hash, wantHash)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	return counter.n, os.Rename(tmp.Name(), dest)
+}
+
+func writeSidecar(path string, obj worker.Object) error {
+	sidecar := objectSidecar{Object: obj}
+	if obj.Metadata != nil && json.Valid([]byte(*obj.Metadata)) {
+		sidecar.Metadata = json.RawMessage(*obj.Metadata)
+	}
+
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,203 @@
+package cfs3
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+
+	"github.com/Hack-Nocturne/cfs3/types"
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/vars"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// modeRollback is recorded for deployments made by Rollback.
+const modeRollback CFS3Mode = "rollback"
+
+// Rollback restores the project to its object set as of a recorded deployment: D1 is
+// rewritten to that state (objects since added are trashed, removed or replaced ones come
+// back) and the state is deployed. When the old deployment's manifest is exactly that state,
+// the Pages rollback endpoint is used instead of a new deployment.
+func (c *CFS3Config) Rollback(deploymentId string) (*ApplyResult, error) {
+	target, err := worker.FetchDeployment(deploymentId)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching deployment: %w", err)
+	}
+	if target == nil || target.ProjectName != c.ProjectName {
+		return nil, fmt.Errorf("deployment %s is not recorded for %s", deploymentId, c.ProjectName)
+	}
+	if target.LastVersionID == 0 {
+		return nil, errors.New("deployment predates the object history, its state is unknown")
+	}
+
+	versions, err := worker.ListObjectVersions(c.ProjectName, "")
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching object versions: %w", err)
+	}
+	slices.Reverse(versions) // oldest first
+
+	current, err := worker.FetchObjectsByPrefix(c.ProjectName, "")
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching objects: %w", err)
+	}
+	state := projectStateAt(target.LastVersionID, versions, current)
+
+	removeIds, restore := stateChanges(state, current)
+	files, hashes := stateFiles(state)
+
+	var deployResp *types.DeploymentResponse
+	manifest, err := utils.FetchDeploymentManifest(vars.CF_ACCOUNT_ID, c.ProjectName, deploymentId)
+	if err == nil && maps.Equal(manifest, hashes) {
+		deployResp, err = utils.RollbackDeployment(vars.CF_ACCOUNT_ID, c.ProjectName, deploymentId)
+		if err != nil {
+			return nil, fmt.Errorf("failure rolling back deployment: %w", err)
+		}
+		fmt.Println("⏪ Rolled back to deployment " + deploymentId)
+	} else {
+		if deployResp, err = c.deployHashes(modeRollback, files, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	restored, versionIds, err := c.applyStateChanges(removeIds, restore)
+	if err != nil {
+		return nil, err
+	}
+
+	// A rollback through the Pages endpoint reuses the old deployment, which is already recorded.
+	if deployResp.ID != deploymentId {
+		c.recordDeploymentObjects(deployResp.ID, objectIDs(restored), removeIds, versionIds)
+	}
+
+	return &ApplyResult{DeploymentID: deployResp.ID, URL: deployResp.URL, Objects: restored, Removed: removeIds}, nil
+}
+
+// stateChanges works out the D1 changes that turn current into state: the IDs of objects to
+// remove and the versions to write back.
+func stateChanges(state map[string]worker.ObjectVersion, current []worker.Object) ([]int64, []worker.ObjectVersion) {
+	var removeIds []int64
+	currentByPath := make(map[string]worker.Object, len(current))
+	for _, obj := range current {
+		if _, keep := state[obj.RelPath]; !keep {
+			removeIds = append(removeIds, obj.ID)
+		}
+		currentByPath[obj.RelPath] = obj
+	}
+
+	var restore []worker.ObjectVersion
+	for _, relPath := range slices.Sorted(maps.Keys(state)) {
+		v := state[relPath]
+		if obj, ok := currentByPath[relPath]; !ok || obj.Hash != v.Hash || !sameMetadata(obj.Metadata, v.Metadata) {
+			restore = append(restore, v)
+		}
+	}
+
+	return removeIds, restore
+}
+
+// stateFiles returns the deployment file set of state, referenced by hash, and its manifest.
+func stateFiles(state map[string]worker.ObjectVersion) (map[string]types.FileContainer, map[string]string) {
+	files := make(map[string]types.FileContainer, len(state))
+	hashes := make(map[string]string, len(state))
+	for relPath, v := range state {
+		files[relPath] = types.FileContainer{
+			ContentType: utils.ExtToMimeType(path.Ext(relPath)),
+			SizeInBytes: max(v.Size, 1),
+			Hash:        v.Hash,
+		}
+		hashes[relPath] = v.Hash
+	}
+	return files, hashes
+}
+
+// applyStateChanges trashes the objects in removeIds and writes the restore versions back to
+// D1, returning the restored objects and the IDs of the object versions written.
+func (c *CFS3Config) applyStateChanges(removeIds []int64, restore []worker.ObjectVersion) ([]worker.Object, []int64, error) {
+	versionIds, err := worker.BulkRemoveObjects(removeIds)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failure removing objects: %w", err)
+	}
+	if len(restore) == 0 {
+		return nil, versionIds, nil
+	}
+
+	objects := make([]worker.Object, len(restore))
+	relPaths := make([]string, len(restore))
+	for i, v := range restore {
+		objects[i] = worker.Object{
+			Hash:        v.Hash,
+			RelPath:     v.RelPath,
+			Name:        v.Name,
+			AddedBy:     v.AddedBy,
+			ProjectName: c.ProjectName,
+			Metadata:    v.Metadata,
+			Size:        v.Size,
+		}
+		relPaths[i] = v.RelPath
+	}
+	added, err := worker.BulkAddObjects(objects)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failure restoring objects: %w", err)
+	}
+
+	restored, err := worker.FetchObjectsByPaths(c.ProjectName, relPaths)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failure fetching restored objects: %w", err)
+	}
+	return restored, append(versionIds, added...), nil
+}
+
+// projectStateAt reconstructs the live objects of a project as of the given version ID from
+// its versions (oldest first) and current objects, keyed by path.
+func projectStateAt(lastVersionId int64, versions []worker.ObjectVersion, current []worker.Object) map[string]worker.ObjectVersion {
+	state := make(map[string]worker.ObjectVersion)
+	decided := make(map[string]bool)
+
+	for _, v := range versions {
+		if v.ID <= lastVersionId {
+			decided[v.RelPath] = true
+			if v.Action == worker.VersionRemoved {
+				delete(state, v.RelPath)
+			} else {
+				state[v.RelPath] = v
+			}
+			continue
+		}
+		if decided[v.RelPath] {
+			continue
+		}
+
+		// The first change after that point: whatever it replaced or removed was live then.
+		decided[v.RelPath] = true
+		if v.Action != worker.VersionAdded {
+			state[v.RelPath] = v
+		}
+	}
+
+	// Objects without any history predate it and were unchanged since.
+	for _, obj := range current {
+		if !decided[obj.RelPath] {
+			state[obj.RelPath] = worker.ObjectVersion{
+				ObjectID:    obj.ID,
+				ProjectName: obj.ProjectName,
+				RelPath:     obj.RelPath,
+				Hash:        obj.Hash,
+				Name:        obj.Name,
+				AddedBy:     obj.AddedBy,
+				Metadata:    obj.Metadata,
+				Size:        obj.Size,
+			}
+		}
+	}
+
+	return state
+}
+
+func sameMetadata(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,154 @@
+package cfs3
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+func strPtr(s string) *string { return &s }
+
+func version(id int64, relPath, hash, action string, metadata *string) worker.ObjectVersion {
+	return worker.ObjectVersion{ID: id, RelPath: relPath, Hash: hash, Action: action, Metadata: metadata}
+}
+
+// stateSummary reduces a state to what a rollback compares: hash and metadata by path.
+func stateSummary(state map[string]worker.ObjectVersion) map[string]string {
+	summary := make(map[string]string, len(state))
+	for relPath, v := range state {
+		summary[relPath] = v.Hash
+		if v.Metadata != nil {
+			summary[relPath] += " " + *v.Metadata
+		}
+	}
+	return summary
+}
+
+func TestProjectStateAt(t *testing.T) {
+	const (
+		added    = worker.VersionAdded
+		replaced = worker.VersionReplaced
+		removed  = worker.VersionRemoved
+	)
+
+	tests := []struct {
+		name     string
+		versions []worker.ObjectVersion // oldest first
+		current  []worker.Object
+		at       int64
+		want     map[string]string
+	}{
+		{
+			name: "replaced since",
+			versions: []worker.ObjectVersion{
+				version(1, "a.txt", "h1", added, nil),
+				version(2, "a.txt", "h1", replaced, nil),
+				version(3, "a.txt", "h2", added, nil),
+			},
+			current: []worker.Object{{ID: 10, RelPath: "a.txt", Hash: "h2"}},
+			at:      1,
+			want:    map[string]string{"a.txt": "h1"},
+		},
+		{
+			name: "added since",
+			versions: []worker.ObjectVersion{
+				version(1, "a.txt", "h1", added, nil),
+				version(2, "b.txt", "h2", added, nil),
+			},
+			current: []worker.Object{{ID: 10, RelPath: "a.txt", Hash: "h1"}, {ID: 11, RelPath: "b.txt", Hash: "h2"}},
+			at:      1,
+			want:    map[string]string{"a.txt": "h1"},
+		},
+		{
+			name: "removed, pinned before the undelete",
+			versions: []worker.ObjectVersion{
+				version(1, "a.txt", "h1", added, nil),
+				version(2, "a.txt", "h1", removed, nil),
+				version(3, "a.txt", "h1", added, nil), // undeleted
+			},
+			current: []worker.Object{{ID: 10, RelPath: "a.txt", Hash: "h1"}},
+			at:      2,
+			want:    map[string]string{},
+		},
+		{
+			name: "undeleted",
+			versions: []worker.ObjectVersion{
+				version(1, "a.txt", "h1", added, nil),
+				version(2, "a.txt", "h1", removed, nil),
+				version(3, "a.txt", "h1", added, nil),
+			},
+			current: []worker.Object{{ID: 10, RelPath: "a.txt", Hash: "h1"}},
+			at:      3,
+			want:    map[string]string{"a.txt": "h1"},
+		},
+		{
+			name: "removed, pinned before the removal",
+			versions: []worker.ObjectVersion{
+				version(1, "a.txt", "h1", added, nil),
+				version(2, "a.txt", "h1", removed, nil),
+			},
+			at:   1,
+			want: map[string]string{"a.txt": "h1"},
+		},
+		{
+			name:     "legacy rows without history",
+			versions: []worker.ObjectVersion{version(1, "new.txt", "h2", added, nil)},
+			current:  []worker.Object{{ID: 5, RelPath: "legacy.txt", Hash: "h0"}, {ID: 10, RelPath: "new.txt", Hash: "h2"}},
+			at:       1,
+			want:     map[string]string{"legacy.txt": "h0", "new.txt": "h2"},
+		},
+		{
+			name: "legacy row replaced after the pin",
+			versions: []worker.ObjectVersion{
+				version(1, "new.txt", "h2", added, nil),
+				version(2, "legacy.txt", "h0", replaced, nil),
+				version(3, "legacy.txt", "h3", added, nil),
+			},
+			current: []worker.Object{{ID: 5, RelPath: "legacy.txt", Hash: "h3"}, {ID: 10, RelPath: "new.txt", Hash: "h2"}},
+			at:      1,
+			want:    map[string]string{"legacy.txt": "h0", "new.txt": "h2"},
+		},
+		{
+			name: "legacy row removed after the pin",
+			versions: []worker.ObjectVersion{
+				version(1, "new.txt", "h2", added, nil),
+				version(2, "legacy.txt", "h0", removed, nil),
+			},
+			current: []worker.Object{{ID: 10, RelPath: "new.txt", Hash: "h2"}},
+			at:      1,
+			want:    map[string]string{"legacy.txt": "h0", "new.txt": "h2"},
+		},
+		{
+			name: "metadata-only edit after the pin",
+			versions: []worker.ObjectVersion{
+				version(1, "a.txt", "h1", added, strPtr(`{"v":1}`)),
+				version(2, "a.txt", "h1", replaced, strPtr(`{"v":1}`)),
+				version(3, "a.txt", "h1", added, strPtr(`{"v":2}`)),
+			},
+			current: []worker.Object{{ID: 10, RelPath: "a.txt", Hash: "h1", Metadata: strPtr(`{"v":2}`)}},
+			at:      1,
+			want:    map[string]string{"a.txt": `h1 {"v":1}`},
+		},
+		{
+			name: "metadata-only edit before the pin",
+			versions: []worker.ObjectVersion{
+				version(1, "a.txt", "h1", added, strPtr(`{"v":1}`)),
+				version(2, "a.txt", "h1", replaced, strPtr(`{"v":1}`)),
+				version(3, "a.txt", "h1", added, strPtr(`{"v":2}`)),
+			},
+			current: []worker.Object{{ID: 10, RelPath: "a.txt", Hash: "h1", Metadata: strPtr(`{"v":2}`)}},
+			at:      3,
+			want:    map[string]string{"a.txt": `h1 {"v":2}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stateSummary(projectStateAt(tt.at, tt.versions, tt.current))
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("projectStateAt(%d) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
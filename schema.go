@@ -0,0 +1,108 @@
+package cfs3
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// SchemaViolation is an object whose metadata doesn't follow the project's schema.
+type SchemaViolation struct {
+	ID      int64  `json:"id"`
+	RelPath string `json:"rel_path"`
+	Error   string `json:"error"`
+}
+
+// metadataSchema returns the schema object metadata must follow: the config's own
+// metadata_schema, else the one registered for the project in D1, nil if there is neither.
+func (c *CFS3Config) metadataSchema() (*utils.JSONSchema, error) {
+	if c.schemaLoaded {
+		return c.schema, nil
+	}
+
+	raw := []byte(c.MetadataSchema)
+	if len(raw) == 0 {
+		registered, err := worker.FetchMetadataSchema(c.ProjectName)
+		if err != nil {
+			return nil, fmt.Errorf("failure fetching metadata schema: %w", err)
+		}
+		if registered != nil {
+			raw = []byte(registered.Schema)
+		}
+	}
+
+	if len(raw) > 0 {
+		schema, err := utils.ParseJSONSchema(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata schema: %w", err)
+		}
+		c.schema = schema
+	}
+	c.schemaLoaded = true
+	return c.schema, nil
+}
+
+// validatePatchMetadata checks the metadata of every patch against the project's schema.
+func (c *CFS3Config) validatePatchMetadata() error {
+	schema, err := c.metadataSchema()
+	if err != nil || schema == nil {
+		return err
+	}
+
+	for i, fp := range c.FilesPatch {
+		metadata := fp.Metadata
+		if metadata == nil {
+			metadata = map[string]any{}
+		}
+		if err := schema.ValidateMetadata(metadata); err != nil {
+			return fmt.Errorf("files__patch[%d] (%s): invalid metadata: %w", i, fp.fileName(), err)
+		}
+	}
+	return nil
+}
+
+// validateObjectMetadata checks stored metadata JSON against the project's schema.
+func validateObjectMetadata(schema *utils.JSONSchema, metadata *string) error {
+	if metadata == nil || *metadata == "null" {
+		return schema.ValidateMetadata(map[string]any{})
+	}
+	// Passed through as is, so large integers aren't rounded to float64 first.
+	return schema.ValidateMetadata(json.RawMessage(*metadata))
+}
+
+// RegisterMetadataSchema stores schema as the metadata schema of the project, replacing any
+// previous one. Objects already stored aren't checked, see CheckMetadata.
+func (c *CFS3Config) RegisterMetadataSchema(schema []byte) error {
+	if _, err := utils.ParseJSONSchema(schema); err != nil {
+		return err
+	}
+
+	by := c.By
+	return worker.SetMetadataSchema(&worker.MetadataSchema{ProjectName: c.ProjectName, Schema: string(schema), By: &by})
+}
+
+// CheckMetadata lists the objects of the project whose metadata violates its schema.
+func (c *CFS3Config) CheckMetadata() ([]SchemaViolation, error) {
+	schema, err := c.metadataSchema()
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("no metadata schema is registered for %s", c.ProjectName)
+	}
+
+	objects, err := worker.FetchObjectsByPrefix(c.ProjectName, "")
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching objects: %w", err)
+	}
+
+	var violations []SchemaViolation
+	for _, obj := range objects {
+		if err := validateObjectMetadata(schema, obj.Metadata); err != nil {
+			violations = append(violations, SchemaViolation{ID: obj.ID, RelPath: obj.RelPath, Error: err.Error()})
+		}
+	}
+	return violations, nil
+}
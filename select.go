@@ -0,0 +1,106 @@
+package cfs3
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// selectObjects returns the objects matched by Prefix and Where.
+func (c *CFS3Config) selectObjects() ([]worker.Object, error) {
+	objects, err := worker.FetchObjectsByMeta(c.ProjectName, strings.TrimPrefix(c.Prefix, "/"), c.Where)
+	if err != nil {
+		return nil, fmt.Errorf("failure selecting objects: %w", err)
+	}
+	return objects, nil
+}
+
+// selectRemovals adds the objects matched by Where to FilesRemove in remove mode.
+func (c *CFS3Config) selectRemovals() error {
+	if c.Mode != ModeRemove || len(c.Where) == 0 {
+		return nil
+	}
+
+	objects, err := c.selectObjects()
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 && len(c.FilesRemove) == 0 {
+		return errors.New("no objects match the 'where' selection")
+	}
+
+	listed := make(map[int64]bool, len(c.FilesRemove))
+	for _, id := range c.FilesRemove {
+		listed[id] = true
+	}
+	for _, obj := range objects {
+		if !listed[obj.ID] {
+			c.FilesRemove = append(c.FilesRemove, obj.ID)
+		}
+	}
+	fmt.Printf("🔎 %d object(s) match the 'where' selection\n", len(objects))
+	return nil
+}
+
+// checkRemovals drops repeated IDs from FilesRemove and rejects IDs that aren't objects of
+// the project; the soft delete itself only goes by ID.
+func (c *CFS3Config) checkRemovals() error {
+	if c.Mode != ModeRemove {
+		return nil
+	}
+
+	c.FilesRemove = uniqueIDs(c.FilesRemove)
+	objects, err := worker.FetchObjectsByIDs(c.ProjectName, c.FilesRemove)
+	if err != nil {
+		return fmt.Errorf("failure fetching objects to remove: %w", err)
+	}
+	if len(objects) == len(c.FilesRemove) {
+		return nil
+	}
+
+	found := make(map[int64]bool, len(objects))
+	for _, obj := range objects {
+		found[obj.ID] = true
+	}
+	var unknown []int64
+	for _, id := range c.FilesRemove {
+		if !found[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	return fmt.Errorf("objects %v don't exist in %s", unknown, c.ProjectName)
+}
+
+// uniqueIDs returns ids without repetitions, in their original order.
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// applyList prints the objects selected by a list-mode config.
+func (c *CFS3Config) applyList() error {
+	objects, err := c.selectObjects()
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		metadata := "-"
+		if obj.Metadata != nil {
+			metadata = *obj.Metadata
+		}
+		fmt.Printf("%d\t%s\t%s\n", obj.ID, obj.RelPath, metadata)
+	}
+
+	c.result = &ApplyResult{Objects: objects}
+	return nil
+}
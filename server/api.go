@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3"
+	"github.com/Hack-Nocturne/cfs3/vars"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+type apiKeyContextKey struct{}
+
+type managementAPI struct {
+	co *cfs3.Coalescer
+
+	mu      sync.Mutex
+	touched map[int64]time.Time // last time each key's last_used_at was written
+}
+
+// NewAPIHandler returns the REST management API. Callers authenticate with a cfs3-issued
+// API key ("Authorization: Bearer cfs3_...") and never need the Cloudflare credentials;
+// the key's owner is recorded as the uploader of every object they add. A key issued for a
+// project is refused for any other; a key without a project can manage every project.
+//
+//	GET    /v1/projects/{project}/objects?prefix=  list objects
+//	POST   /v1/projects/{project}/objects          upload files (multipart, field "file")
+//	DELETE /v1/projects/{project}/objects/{id}     remove an object
+func NewAPIHandler(co *cfs3.Coalescer) http.Handler {
+	api := &managementAPI{co: co, touched: make(map[int64]time.Time)}
+
+	// Keys are checked per route, the project path value is only set once a route matched.
+	mux := http.NewServeMux()
+	mux.Handle("GET /v1/projects/{project}/objects", api.requireAPIKey(api.listObjects))
+	mux.Handle("POST /v1/projects/{project}/objects", api.requireAPIKey(api.uploadObjects))
+	mux.Handle("DELETE /v1/projects/{project}/objects/{id}", api.requireAPIKey(api.removeObject))
+
+	return mux
+}
+
+// requireAPIKey rejects requests without a valid API key for the requested project and
+// stores the key in the request context.
+func (api *managementAPI) requireAPIKey(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			writeError(w, http.StatusUnauthorized, errors.New("missing bearer API key"))
+			return
+		}
+
+		record, err := cfs3.AuthenticateAPIKey(strings.TrimSpace(key))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, cfs3.ErrInvalidAPIKey) {
+				status = http.StatusUnauthorized
+			}
+			writeError(w, status, err)
+			return
+		}
+		if err := cfs3.AuthorizeProject(record, r.PathValue("project")); err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+		api.touchAPIKey(record.ID)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, record)))
+	})
+}
+
+// touchAPIKey records the use of a key, at most once per API_KEY_TOUCH_INTERVAL so requests
+// don't each cost a D1 write.
+func (api *managementAPI) touchAPIKey(id int64) {
+	now := time.Now()
+	api.mu.Lock()
+	if last, ok := api.touched[id]; ok && now.Sub(last) < vars.API_KEY_TOUCH_INTERVAL {
+		api.mu.Unlock()
+		return
+	}
+	api.touched[id] = now
+	api.mu.Unlock()
+
+	if err := worker.TouchAPIKey(id); err != nil {
+		fmt.Printf("⚠️ Failed to record use of API key #%d: %v\n", id, err)
+	}
+}
+
+func apiKeyFrom(r *http.Request) *worker.APIKey {
+	return r.Context().Value(apiKeyContextKey{}).(*worker.APIKey)
+}
+
+func (api *managementAPI) listObjects(w http.ResponseWriter, r *http.Request) {
+	objects, err := worker.FetchObjectsByPrefix(r.PathValue("project"), r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, objects)
+}
+
+// uploadObjects patches every "file" part of a multipart form. Optional form fields:
+// remote_dir (defaults to "/"), remote_path (single file only) and metadata (a JSON object).
+func (api *managementAPI) uploadObjects(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(vars.MAX_ASSET_SIZE)*40)
+	if err := r.ParseMultipartForm(32 * vars.KB_SIZE * vars.KB_SIZE); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("parsing multipart form: %w", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no 'file' parts in form"))
+		return
+	}
+
+	remoteDir := r.FormValue("remote_dir")
+	if remoteDir == "" {
+		remoteDir = "/"
+	}
+	remotePath := r.FormValue("remote_path")
+	if remotePath != "" && len(files) > 1 {
+		writeError(w, http.StatusBadRequest, errors.New("'remote_path' can only be used with a single file"))
+		return
+	}
+
+	var metadata map[string]any
+	if raw := r.FormValue("metadata"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("parsing metadata JSON: %w", err))
+			return
+		}
+	}
+
+	spoolDir, err := os.MkdirTemp("", vars.API_SPOOL_DIR_PATTERN)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer os.RemoveAll(spoolDir)
+
+	op := &cfs3.CFS3Config{
+		By:          apiKeyFrom(r).Owner,
+		Mode:        cfs3.ModePatch,
+		ProjectName: r.PathValue("project"),
+	}
+	for i, fh := range files {
+		// Keep the original extension, the asset hash and content type depend on it.
+		localFile := filepath.Join(spoolDir, strconv.Itoa(i)+filepath.Ext(fh.Filename))
+		if err := spoolPart(localFile, fh); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		op.FilesPatch = append(op.FilesPatch, cfs3.FilePatch{
+			LocalFile:  localFile,
+			Temporary:  true,
+			Remote:     remoteDir,
+			RemotePath: remotePath,
+			Name:       filepath.Base(fh.Filename),
+			Metadata:   metadata,
+		})
+	}
+
+	// The spooled files must outlive the batch, so don't give up when the client does.
+	result, err := api.co.Submit(context.WithoutCancel(r.Context()), op)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func spoolPart(dest string, fh *multipart.FileHeader) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, src); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func (api *managementAPI) removeObject(w http.ResponseWriter, r *http.Request) {
+	project := r.PathValue("project")
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("object id must be an integer"))
+		return
+	}
+
+	objects, err := worker.FetchObjectsByIDs(project, []int64{id})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(objects) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("object %d not found in %s", id, project))
+		return
+	}
+
+	op := &cfs3.CFS3Config{
+		By:          apiKeyFrom(r).Owner,
+		Mode:        cfs3.ModeRemove,
+		ProjectName: project,
+		FilesRemove: []int64{id},
+	}
+	result, err := api.co.Submit(r.Context(), op)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
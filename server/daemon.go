@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/Hack-Nocturne/cfs3"
+)
+
+// DaemonOptions restricts the sources the daemon reads on behalf of its callers, who are
+// not authenticated.
+type DaemonOptions struct {
+	Root      string // local_file paths must lie inside this directory, local files are refused without it
+	AllowURLs bool   // accept http(s) sources; off by default, the daemon would fetch any URL it can reach
+}
+
+// NewDaemonHandler returns the HTTP handler of the coalescing daemon.
+//
+// POST /ops accepts a patch or remove operation shaped like a cfs3 config file, queues it
+// and responds once the batched deployment it ended up in has been applied, with the
+// deployment URL and the D1 IDs of the affected objects.
+func NewDaemonHandler(co *cfs3.Coalescer, opts DaemonOptions) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /ops", func(w http.ResponseWriter, r *http.Request) {
+		var op cfs3.CFS3Config
+		if err := json.NewDecoder(r.Body).Decode(&op); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("parsing operation JSON: %w", err))
+			return
+		}
+		if err := opts.checkSources(op.FilesPatch); err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+
+		result, err := co.Submit(r.Context(), &op)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, result)
+	})
+
+	return mux
+}
+
+// checkSources refuses URL sources unless they are allowed and local files outside the root.
+// Relative local paths are resolved against the root and rewritten to absolute ones.
+func (opts DaemonOptions) checkSources(patches []cfs3.FilePatch) error {
+	for i, fp := range patches {
+		switch {
+		case fp.LocalFile == "-":
+			// The coalescer refuses stdin sources.
+		case strings.HasPrefix(fp.LocalFile, "http://") || strings.HasPrefix(fp.LocalFile, "https://"):
+			if !opts.AllowURLs {
+				return fmt.Errorf("%s: URL sources are disabled", fp.LocalFile)
+			}
+		default:
+			resolved, err := opts.resolveLocal(fp.LocalFile)
+			if err != nil {
+				return fmt.Errorf("%s: %w", fp.LocalFile, err)
+			}
+			patches[i].LocalFile = resolved
+		}
+	}
+	return nil
+}
+
+// resolveLocal returns the absolute path of name below the root. Symlinks are followed for
+// the check so that none of them can point outside it, the path itself keeps its name as
+// the extension and download name depend on it.
+func (opts DaemonOptions) resolveLocal(name string) (string, error) {
+	if opts.Root == "" {
+		return "", errors.New("local files are disabled, start the daemon with a root directory")
+	}
+
+	root, err := filepath.Abs(opts.Root)
+	if err != nil {
+		return "", err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(root, name)
+	}
+	resolved, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("outside of the daemon's root directory")
+	}
+	return filepath.Clean(name), nil
+}
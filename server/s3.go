@@ -0,0 +1,561 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3"
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/vars"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// S3Options configures the S3-compatible gateway.
+type S3Options struct {
+	By string // recorded as the uploader of objects written through the gateway
+}
+
+type s3Gateway struct {
+	co *cfs3.Coalescer
+	by string
+}
+
+// NewS3Handler returns an HTTP handler speaking a subset of the S3 REST API (path-style only).
+// Buckets map to Pages projects and object keys to worker.Object.RelPath. Writes go through
+// the coalescer, so concurrent PutObject calls share deployments.
+//
+// Supported: HeadBucket, CreateBucket (existing projects only), ListObjects (v1 and v2),
+// PutObject, GetObject (redirect to the deployed file), HeadObject, DeleteObject and
+// DeleteObjects. Request signatures are not verified.
+func NewS3Handler(co *cfs3.Coalescer, opts S3Options) http.Handler {
+	if opts.By == "" {
+		opts.By = "s3-gateway"
+	}
+	g := &s3Gateway{co: co, by: opts.By}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("HEAD /{bucket}", g.headBucket)
+	mux.HandleFunc("PUT /{bucket}", g.headBucket)
+	mux.HandleFunc("GET /{bucket}", g.listObjects)
+	mux.HandleFunc("POST /{bucket}", g.deleteObjects)
+	mux.HandleFunc("PUT /{bucket}/{key...}", g.putObject)
+	mux.HandleFunc("GET /{bucket}/{key...}", g.getObject)
+	mux.HandleFunc("HEAD /{bucket}/{key...}", g.headObject)
+	mux.HandleFunc("DELETE /{bucket}/{key...}", g.deleteObject)
+
+	return mux
+}
+
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	fmt.Fprint(w, xml.Header)
+	xml.NewEncoder(w).Encode(s3Error{Code: code, Message: message, Resource: r.URL.Path})
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
+
+// objectETag derives an ETag from the Pages asset hash. It is formatted like a multipart
+// ETag so clients don't mistake it for an MD5 digest of the content.
+func objectETag(obj worker.Object) string {
+	return `"` + obj.Hash + `-1"`
+}
+
+func objectLastModified(obj worker.Object) time.Time {
+	if !obj.UpdatedAt.IsZero() {
+		return obj.UpdatedAt.UTC()
+	}
+	if !obj.CreatedAt.IsZero() {
+		return obj.CreatedAt.UTC()
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// cleanKey reports whether key is stored under exactly this path. Patches clean their
+// remote path, so a key like "a//b" or "a/./b" would land at another path than the one
+// later reads, deletes and listings look up.
+func cleanKey(key string) bool {
+	return key == strings.TrimPrefix(path.Clean("/"+key), "/")
+}
+
+// findObject looks up a single object by key, returning nil if it does not exist.
+// Keys that aren't clean are never stored, they are simply not found.
+func findObject(bucket, key string) (*worker.Object, error) {
+	objects, err := worker.FetchObjectsByPaths(bucket, []string{key})
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+	return &objects[0], nil
+}
+
+func (g *s3Gateway) headBucket(w http.ResponseWriter, r *http.Request) {
+	if _, err := utils.FetchProject(vars.CF_ACCOUNT_ID, r.PathValue("bucket")); err != nil {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket", "Pages project not found")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *s3Gateway) putObject(w http.ResponseWriter, r *http.Request) {
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+	if key == "" {
+		g.headBucket(w, r)
+		return
+	}
+	if r.Header.Get("x-amz-copy-source") != "" {
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented", "CopyObject is not supported")
+		return
+	}
+	if !cleanKey(key) {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument",
+			"Object keys must not contain empty, '.' or '..' segments or end with '/'.")
+		return
+	}
+
+	var body io.ReadCloser = r.Body
+	if strings.HasPrefix(r.Header.Get("x-amz-content-sha256"), "STREAMING-") ||
+		strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") {
+		body = io.NopCloser(&awsChunkedReader{r: bufio.NewReader(r.Body)})
+	}
+	// Limit the decoded object rather than the chunk framing around it.
+	body = http.MaxBytesReader(w, body, vars.MAX_ASSET_SIZE)
+
+	// Spool the body to disk so it goes through the regular hashing and upload pipeline.
+	spoolDir, err := os.MkdirTemp("", vars.S3_SPOOL_DIR_PATTERN)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	defer os.RemoveAll(spoolDir)
+
+	// Keep the key's extension, the asset hash and content type depend on it.
+	localFile := filepath.Join(spoolDir, "object"+path.Ext(key))
+	if err := spool(localFile, body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeS3Error(w, r, http.StatusBadRequest, "EntityTooLarge",
+				fmt.Sprintf("Your proposed upload exceeds the maximum allowed object size of %d bytes.", tooLarge.Limit))
+			return
+		}
+		writeS3Error(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	metadata := make(map[string]any)
+	for name, values := range r.Header {
+		if metaKey, ok := strings.CutPrefix(strings.ToLower(name), "x-amz-meta-"); ok && len(values) > 0 {
+			metadata[metaKey] = values[0]
+		}
+	}
+
+	op := &cfs3.CFS3Config{
+		By:          g.by,
+		Mode:        cfs3.ModePatch,
+		ProjectName: bucket,
+		FilesPatch: []cfs3.FilePatch{{
+			LocalFile:  localFile,
+			Temporary:  true,
+			RemotePath: key,
+			Name:       path.Base(key),
+			Metadata:   metadata,
+			// PutObject replaces the key whatever the project's default policy is.
+			OnConflict: cfs3.ConflictOverwrite,
+		}},
+	}
+
+	// The spooled file must outlive the batch, so don't give up when the client does.
+	result, err := g.co.Submit(context.WithoutCancel(r.Context()), op)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	if len(result.Objects) > 0 {
+		w.Header().Set("ETag", objectETag(result.Objects[0]))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func spool(dest string, body io.Reader) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, body); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (g *s3Gateway) getObject(w http.ResponseWriter, r *http.Request) {
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+	if key == "" {
+		g.listObjects(w, r)
+		return
+	}
+
+	obj, err := findObject(bucket, key)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	if obj == nil {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+
+	baseURL, err := utils.ProjectURL(vars.CF_ACCOUNT_ID, bucket)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	location := baseURL + (&url.URL{Path: "/" + obj.RelPath}).EscapedPath()
+	http.Redirect(w, r, location, http.StatusTemporaryRedirect)
+}
+
+func (g *s3Gateway) headObject(w http.ResponseWriter, r *http.Request) {
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+	if key == "" {
+		g.headBucket(w, r)
+		return
+	}
+
+	obj, err := findObject(bucket, key)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	if obj == nil {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+
+	if obj.Metadata != nil {
+		var metadata map[string]any
+		if json.Unmarshal([]byte(*obj.Metadata), &metadata) == nil {
+			for k, v := range metadata {
+				w.Header().Set("x-amz-meta-"+k, fmt.Sprint(v))
+			}
+		}
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	w.Header().Set("Content-Type", utils.ExtToMimeType(path.Ext(obj.RelPath)))
+	w.Header().Set("ETag", objectETag(*obj))
+	w.Header().Set("Last-Modified", objectLastModified(*obj).Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *s3Gateway) deleteObject(w http.ResponseWriter, r *http.Request) {
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+
+	if _, err := g.removeKeys(r.Context(), bucket, []string{key}); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeKeys removes the objects stored at keys. Keys that don't exist are ignored, as in S3.
+func (g *s3Gateway) removeKeys(ctx context.Context, bucket string, keys []string) ([]worker.Object, error) {
+	objects, err := worker.FetchObjectsByPaths(bucket, keys)
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+
+	ids := make([]int64, len(objects))
+	for i, obj := range objects {
+		ids[i] = obj.ID
+	}
+
+	op := &cfs3.CFS3Config{
+		By:          g.by,
+		Mode:        cfs3.ModeRemove,
+		ProjectName: bucket,
+		FilesRemove: ids,
+	}
+	if _, err := g.co.Submit(ctx, op); err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+type deleteRequest struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type deletedObject struct {
+	Key string `xml:"Key"`
+}
+
+type deleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+func (g *s3Gateway) deleteObjects(w http.ResponseWriter, r *http.Request) {
+	if !r.URL.Query().Has("delete") {
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented", "Only multi-object delete is supported")
+		return
+	}
+
+	var req deleteRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+
+	keys := make([]string, len(req.Objects))
+	for i, obj := range req.Objects {
+		keys[i] = obj.Key
+	}
+
+	result := deleteResult{Xmlns: s3Namespace}
+	if _, err := g.removeKeys(r.Context(), r.PathValue("bucket"), keys); err != nil {
+		for _, key := range keys {
+			result.Errors = append(result.Errors, deleteError{Key: key, Code: "InternalError", Message: err.Error()})
+		}
+	} else if !req.Quiet {
+		for _, key := range keys {
+			result.Deleted = append(result.Deleted, deletedObject{Key: key})
+		}
+	}
+
+	writeXML(w, result)
+}
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+type listedObject struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Xmlns       string   `xml:"xmlns,attr"`
+	Name        string   `xml:"Name"`
+	Prefix      string   `xml:"Prefix"`
+	Delimiter   string   `xml:"Delimiter,omitempty"`
+	MaxKeys     int      `xml:"MaxKeys"`
+	IsTruncated bool     `xml:"IsTruncated"`
+
+	// ListObjects (v1)
+	Marker     *string `xml:"Marker"`
+	NextMarker string  `xml:"NextMarker,omitempty"`
+
+	// ListObjectsV2
+	KeyCount              *int   `xml:"KeyCount"`
+	ContinuationToken     string `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string `xml:"StartAfter,omitempty"`
+
+	Contents       []listedObject `xml:"Contents"`
+	CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+}
+
+func (g *s3Gateway) listObjects(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	bucket := r.PathValue("bucket")
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	isV2 := query.Get("list-type") == "2"
+
+	maxKeys := 1000
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "invalid max-keys")
+			return
+		}
+		maxKeys = min(n, 1000)
+	}
+
+	// Listing resumes after this key (or common prefix).
+	after := query.Get("marker")
+	if isV2 {
+		after = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "invalid continuation token")
+				return
+			}
+			after = string(decoded)
+		}
+	}
+
+	objects, err := worker.FetchObjectsByPrefix(bucket, prefix)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	result := listBucketResult{
+		Xmlns:     s3Namespace,
+		Name:      bucket,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		MaxKeys:   maxKeys,
+	}
+
+	count := 0
+	lastKey := ""
+	seenPrefixes := make(map[string]bool)
+	for _, obj := range objects {
+		key := obj.RelPath
+		if key <= after {
+			continue
+		}
+
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				cp := key[:len(prefix)+i+len(delimiter)]
+				if cp <= after || seenPrefixes[cp] {
+					continue
+				}
+				if count == maxKeys {
+					result.IsTruncated = true
+					break
+				}
+				seenPrefixes[cp] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: cp})
+				count++
+				lastKey = cp
+				continue
+			}
+		}
+
+		if count == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		result.Contents = append(result.Contents, listedObject{
+			Key:          key,
+			LastModified: objectLastModified(obj).Format("2006-01-02T15:04:05.000Z"),
+			ETag:         objectETag(obj),
+			Size:         obj.Size,
+			StorageClass: "STANDARD",
+		})
+		count++
+		lastKey = key
+	}
+
+	if isV2 {
+		result.KeyCount = &count
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = query.Get("start-after")
+		if result.IsTruncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(lastKey))
+		}
+	} else {
+		marker := query.Get("marker")
+		result.Marker = &marker
+		if result.IsTruncated {
+			result.NextMarker = lastKey
+		}
+	}
+
+	writeXML(w, result)
+}
+
+// awsChunkedReader decodes the aws-chunked encoding used by SigV4 streaming uploads:
+// "<hex size>[;chunk-signature=...]\r\n<data>\r\n" repeated, ending with a zero-sized chunk.
+// Chunk signatures and trailing checksums are not verified.
+type awsChunkedReader struct {
+	r         *bufio.Reader
+	remaining int64
+	done      bool
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue // the CRLF terminating the previous chunk's data
+		}
+
+		sizeHex, _, _ := strings.Cut(line, ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size < 0 {
+			return 0, fmt.Errorf("malformed aws-chunked header %q", line)
+		}
+		if size == 0 {
+			c.done = true
+			return 0, io.EOF
+		}
+		c.remaining = size
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if errors.Is(err, io.EOF) && c.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
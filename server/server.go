@@ -0,0 +1,30 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Listen opens a TCP listener on addr, or a Unix socket when addr has the form "unix:/path".
+// A stale socket file left behind by a previous run is removed first.
+func Listen(addr string) (net.Listener, error) {
+	if socketPath, ok := strings.CutPrefix(addr, "unix:"); ok {
+		os.Remove(socketPath)
+		return net.Listen("unix", socketPath)
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
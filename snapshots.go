@@ -0,0 +1,330 @@
+package cfs3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/vars"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// modeSnapshotRestore is recorded for deployments made by RestoreSnapshot.
+const modeSnapshotRestore CFS3Mode = "snapshot-restore"
+
+// SnapshotFileVersion is the format version of SnapshotFile written by ExportSnapshot.
+const SnapshotFileVersion = 1
+
+// SnapshotFile is the portable JSON form of a snapshot.
+type SnapshotFile struct {
+	Version     int              `json:"version"`
+	ProjectName string           `json:"project_name"` // project holding the snapshot's assets
+	Name        string           `json:"name"`
+	By          *string          `json:"by"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Objects     []SnapshotObject `json:"objects"`
+}
+
+// SnapshotObject is one object of a SnapshotFile.
+type SnapshotObject struct {
+	RelPath  string          `json:"rel_path"`
+	Hash     string          `json:"hash"`
+	Name     string          `json:"name"`
+	AddedBy  *string         `json:"added_by"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	Size     int64           `json:"size"`
+}
+
+// SnapshotDiff lists how a project changed since a snapshot.
+type SnapshotDiff struct {
+	Snapshot string   `json:"snapshot"`
+	Added    []string `json:"added"`   // live now but not in the snapshot
+	Removed  []string `json:"removed"` // in the snapshot but no longer live
+	Changed  []string `json:"changed"` // live with a different hash or metadata
+}
+
+// Clean reports whether the project still matches the snapshot.
+func (d *SnapshotDiff) Clean() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// CreateSnapshot records the project's current objects under name, which must be unused.
+func (c *CFS3Config) CreateSnapshot(name string) (*worker.Snapshot, error) {
+	if name == "" {
+		return nil, errors.New("snapshot name is required")
+	}
+	if existing, _, err := worker.FetchSnapshot(c.ProjectName, name); err != nil {
+		return nil, fmt.Errorf("failure fetching snapshot: %w", err)
+	} else if existing != nil {
+		return nil, fmt.Errorf("snapshot %q already exists in %s", name, c.ProjectName)
+	}
+
+	objects, err := worker.FetchObjectsByPrefix(c.ProjectName, "")
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching objects: %w", err)
+	}
+
+	entries := make([]worker.SnapshotEntry, len(objects))
+	for i, obj := range objects {
+		entries[i] = worker.SnapshotEntry{
+			RelPath:  obj.RelPath,
+			Hash:     obj.Hash,
+			Name:     obj.Name,
+			AddedBy:  obj.AddedBy,
+			Metadata: obj.Metadata,
+			Size:     obj.Size,
+		}
+	}
+
+	by := c.By
+	snapshot := &worker.Snapshot{ProjectName: c.ProjectName, Name: name, By: &by}
+	if err := worker.CreateSnapshot(snapshot, entries); err != nil {
+		return nil, fmt.Errorf("failure saving snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// DiffSnapshot compares the project's current objects with the named snapshot.
+func (c *CFS3Config) DiffSnapshot(name string) (*SnapshotDiff, error) {
+	_, state, err := c.snapshotState(name)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := worker.FetchObjectsByPrefix(c.ProjectName, "")
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching objects: %w", err)
+	}
+
+	diff := &SnapshotDiff{Snapshot: name}
+	live := make(map[string]bool, len(current))
+	for _, obj := range current {
+		live[obj.RelPath] = true
+		v, ok := state[obj.RelPath]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, obj.RelPath)
+		case v.Hash != obj.Hash || !sameMetadata(v.Metadata, obj.Metadata):
+			diff.Changed = append(diff.Changed, obj.RelPath)
+		}
+	}
+	for relPath := range state {
+		if !live[relPath] {
+			diff.Removed = append(diff.Removed, relPath)
+		}
+	}
+
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.Sort(diff.Changed)
+
+	return diff, nil
+}
+
+// RestoreSnapshot brings the project back to the named snapshot: objects added since are
+// trashed, removed or changed ones are written back and the result is deployed by hash.
+// Assets of a snapshot imported from another project that Cloudflare doesn't hold for this
+// one are downloaded from the source project's site and checked against their hash first.
+// Nothing is deployed when the project already matches the snapshot.
+func (c *CFS3Config) RestoreSnapshot(name string) (*ApplyResult, error) {
+	snapshot, state, err := c.snapshotState(name)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := worker.FetchObjectsByPrefix(c.ProjectName, "")
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching objects: %w", err)
+	}
+
+	removeIds, restore := stateChanges(state, current)
+	if len(removeIds) == 0 && len(restore) == 0 {
+		return &ApplyResult{}, nil
+	}
+
+	files, _ := stateFiles(state)
+	if snapshot.SourceProject != "" && snapshot.SourceProject != c.ProjectName {
+		baseURL, err := utils.ProjectURL(vars.CF_ACCOUNT_ID, snapshot.SourceProject)
+		if err != nil {
+			return nil, fmt.Errorf("failure resolving %s: %w", snapshot.SourceProject, err)
+		}
+
+		client := &http.Client{Timeout: vars.SOURCE_FETCH_TIMEOUT}
+		for relPath, file := range files {
+			assetURL := strings.TrimSuffix(baseURL, "/") + (&url.URL{Path: "/" + relPath}).EscapedPath()
+			ext := strings.TrimPrefix(path.Ext(relPath), ".")
+			file.Open = func() (io.ReadCloser, error) {
+				return fetchAsset(client, assetURL, ext, file.Hash)
+			}
+			files[relPath] = file
+		}
+	}
+
+	deployResp, err := c.deployHashes(modeSnapshotRestore, files, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	restored, versionIds, err := c.applyStateChanges(removeIds, restore)
+	if err != nil {
+		return nil, err
+	}
+	c.recordDeploymentObjects(deployResp.ID, objectIDs(restored), removeIds, versionIds)
+
+	return &ApplyResult{DeploymentID: deployResp.ID, URL: deployResp.URL, Objects: restored, Removed: removeIds}, nil
+}
+
+// ExportSnapshot writes the named snapshot to w as a SnapshotFile.
+func (c *CFS3Config) ExportSnapshot(name string, w io.Writer) error {
+	snapshot, entries, err := worker.FetchSnapshot(c.ProjectName, name)
+	if err != nil {
+		return fmt.Errorf("failure fetching snapshot: %w", err)
+	}
+	if snapshot == nil {
+		return fmt.Errorf("snapshot %q not found in %s", name, c.ProjectName)
+	}
+
+	file := SnapshotFile{
+		Version:     SnapshotFileVersion,
+		ProjectName: snapshot.ProjectName,
+		Name:        snapshot.Name,
+		By:          snapshot.By,
+		CreatedAt:   snapshot.CreatedAt,
+		Objects:     make([]SnapshotObject, len(entries)),
+	}
+	// The assets of an imported snapshot still live in the project it was taken of.
+	if snapshot.SourceProject != "" {
+		file.ProjectName = snapshot.SourceProject
+	}
+	for i, e := range entries {
+		file.Objects[i] = SnapshotObject{RelPath: e.RelPath, Hash: e.Hash, Name: e.Name, AddedBy: e.AddedBy, Size: e.Size}
+		if e.Metadata != nil {
+			file.Objects[i].Metadata = json.RawMessage(*e.Metadata)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(file)
+}
+
+// ImportSnapshot stores a SnapshotFile read from r as a snapshot of this project, under name
+// or the file's own name when empty. Restore it with RestoreSnapshot.
+func (c *CFS3Config) ImportSnapshot(r io.Reader, name string) (*worker.Snapshot, error) {
+	var file SnapshotFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failure decoding snapshot file: %w", err)
+	}
+	if file.Version < 1 || file.Version > SnapshotFileVersion {
+		return nil, fmt.Errorf("unsupported snapshot file version %d", file.Version)
+	}
+
+	if name == "" {
+		name = file.Name
+	}
+	if name == "" {
+		return nil, errors.New("snapshot name is required")
+	}
+	if existing, _, err := worker.FetchSnapshot(c.ProjectName, name); err != nil {
+		return nil, fmt.Errorf("failure fetching snapshot: %w", err)
+	} else if existing != nil {
+		return nil, fmt.Errorf("snapshot %q already exists in %s", name, c.ProjectName)
+	}
+
+	entries := make([]worker.SnapshotEntry, 0, len(file.Objects))
+	seen := make(map[string]bool, len(file.Objects))
+	for _, obj := range file.Objects {
+		relPath := strings.Trim(path.Clean("/"+obj.RelPath), "/")
+		if relPath == "" || obj.Hash == "" {
+			return nil, fmt.Errorf("snapshot file has an invalid object %q", obj.RelPath)
+		}
+		if seen[relPath] {
+			return nil, fmt.Errorf("snapshot file lists %s twice", relPath)
+		}
+		seen[relPath] = true
+
+		entry := worker.SnapshotEntry{RelPath: relPath, Hash: obj.Hash, Name: obj.Name, AddedBy: obj.AddedBy, Size: obj.Size}
+		if len(obj.Metadata) > 0 && string(obj.Metadata) != "null" {
+			metadata := string(obj.Metadata)
+			entry.Metadata = &metadata
+		}
+		entries = append(entries, entry)
+	}
+
+	snapshot := &worker.Snapshot{ProjectName: c.ProjectName, Name: name, By: file.By}
+	if file.ProjectName != c.ProjectName {
+		snapshot.SourceProject = file.ProjectName
+	}
+	if err := worker.CreateSnapshot(snapshot, entries); err != nil {
+		return nil, fmt.Errorf("failure saving snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// snapshotState loads the named snapshot as a project state keyed by path.
+func (c *CFS3Config) snapshotState(name string) (*worker.Snapshot, map[string]worker.ObjectVersion, error) {
+	snapshot, entries, err := worker.FetchSnapshot(c.ProjectName, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failure fetching snapshot: %w", err)
+	}
+	if snapshot == nil {
+		return nil, nil, fmt.Errorf("snapshot %q not found in %s", name, c.ProjectName)
+	}
+
+	state := make(map[string]worker.ObjectVersion, len(entries))
+	for _, e := range entries {
+		state[e.RelPath] = worker.ObjectVersion{
+			ProjectName: c.ProjectName,
+			RelPath:     e.RelPath,
+			Hash:        e.Hash,
+			Name:        e.Name,
+			AddedBy:     e.AddedBy,
+			Metadata:    e.Metadata,
+			Size:        e.Size,
+		}
+	}
+
+	return snapshot, state, nil
+}
+
+// fetchAsset downloads a deployed file and checks it against its asset hash.
+func fetchAsset(client *http.Client, assetURL, ext, wantHash string) (io.ReadCloser, error) {
+	resp, err := client.Get(assetURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected HTTP status %s", assetURL, resp.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, int64(vars.MAX_ASSET_SIZE)+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", assetURL, err)
+	}
+	if len(content) > vars.MAX_ASSET_SIZE {
+		return nil, fmt.Errorf("fetching %s: larger than the maximum asset size", assetURL)
+	}
+
+	hash, err := utils.HashAsset(bytes.NewReader(content), ext)
+	if err != nil {
+		return nil, err
+	}
+	if hash != wantHash {
+		return nil, fmt.Errorf("fetching %s: content doesn't match hash %s", assetURL, wantHash)
+	}
+
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
@@ -0,0 +1,241 @@
+package cfs3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Hack-Nocturne/cfs3/types"
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/vars"
+)
+
+// extension returns the file extension of the patch without the leading dot.
+func (fp FilePatch) extension() string {
+	if fp.Reader != nil {
+		return strings.TrimPrefix(path.Ext(fp.Name), ".")
+	}
+	return strings.TrimPrefix(filepath.Ext(fp.LocalFile), ".")
+}
+
+// source hashes the content of the patch. Content that is not a plain local file is
+// returned as an asset Deploy can open again for the upload.
+func (fp FilePatch) source() (utils.FileHashes, *types.Asset, error) {
+	switch {
+	case fp.extracted != nil:
+		return fp.extracted.hashes, &fp.extracted.asset, nil
+	case fp.Reader != nil:
+		return readerSource(fp.Reader, fp.Size, fp.extension())
+	case fp.FS != nil:
+		return fsSource(fp.FS, fp.LocalFile, fp.extension())
+	case fp.Temporary:
+		return tempFileSource(fp.LocalFile, fp.extension())
+	}
+
+	hashes, err := utils.HashFile(fp.LocalFile)
+	return hashes, nil, err
+}
+
+// assetHash hashes the content of the patch as a file with extension ext. asset is the
+// patch's source as returned by source, nil for a plain local file.
+func (fp FilePatch) assetHash(asset *types.Asset, ext string) (string, error) {
+	var rc io.ReadCloser
+	var err error
+	if asset != nil {
+		rc, err = asset.Open()
+	} else {
+		rc, err = os.Open(fp.LocalFile)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	return utils.HashAsset(rc, ext)
+}
+
+// readerSource hashes r. Seekable readers are rewound for every upload attempt,
+// anything else is buffered in memory.
+func readerSource(r io.Reader, size int64, ext string) (utils.FileHashes, *types.Asset, error) {
+	var asset types.Asset
+
+	if rs, ok := r.(io.ReadSeeker); ok {
+		start, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return utils.FileHashes{}, nil, err
+		}
+		end, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return utils.FileHashes{}, nil, err
+		}
+
+		asset.Size = end - start
+		asset.Open = func() (io.ReadCloser, error) {
+			if _, err := rs.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return io.NopCloser(rs), nil
+		}
+	} else {
+		data, err := io.ReadAll(io.LimitReader(r, vars.MAX_ASSET_SIZE+1))
+		if err != nil {
+			return utils.FileHashes{}, nil, err
+		}
+
+		asset.Size = int64(len(data))
+		asset.Open = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+
+	if asset.Size > vars.MAX_ASSET_SIZE {
+		return utils.FileHashes{}, nil, fmt.Errorf("content exceeds maximum allowed %d bytes", vars.MAX_ASSET_SIZE)
+	}
+	if size > 0 && asset.Size != size {
+		return utils.FileHashes{}, nil, fmt.Errorf("reader yields %d bytes, expected %d", asset.Size, size)
+	}
+
+	return hashAsset(asset, ext)
+}
+
+// fsSource hashes the named file inside fsys.
+func fsSource(fsys fs.FS, name, ext string) (utils.FileHashes, *types.Asset, error) {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return utils.FileHashes{}, nil, err
+	}
+	if info.IsDir() {
+		return utils.FileHashes{}, nil, errors.New("is a directory")
+	}
+
+	asset := types.Asset{
+		Size: info.Size(),
+		Open: func() (io.ReadCloser, error) { return fsys.Open(name) },
+	}
+	return hashAsset(asset, ext)
+}
+
+// tempFileSource hashes a spooled local file as an asset, so neither it nor Deploy puts the
+// file in the hash cache.
+func tempFileSource(name, ext string) (utils.FileHashes, *types.Asset, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return utils.FileHashes{}, nil, err
+	}
+	if info.IsDir() {
+		return utils.FileHashes{}, nil, errors.New("is a directory")
+	}
+
+	asset := types.Asset{
+		Size: info.Size(),
+		Open: func() (io.ReadCloser, error) { return os.Open(name) },
+	}
+	return hashAsset(asset, ext)
+}
+
+// hashAsset reads the asset once to compute both hashes and records the asset hash on it.
+func hashAsset(asset types.Asset, ext string) (utils.FileHashes, *types.Asset, error) {
+	rc, err := asset.Open()
+	if err != nil {
+		return utils.FileHashes{}, nil, err
+	}
+	defer rc.Close()
+
+	hashes, err := utils.HashReader(rc, ext)
+	if err != nil {
+		return utils.FileHashes{}, nil, err
+	}
+
+	asset.Hash = hashes.Blake3
+	return hashes, &asset, nil
+}
+
+// isStdin reports whether the patch reads its content from standard input.
+func (fp FilePatch) isStdin() bool {
+	return fp.Reader == nil && fp.FS == nil && fp.LocalFile == "-"
+}
+
+// isURL reports whether the patch downloads its content from an http(s) URL.
+func (fp FilePatch) isURL() bool {
+	return fp.Reader == nil && fp.FS == nil &&
+		(strings.HasPrefix(fp.LocalFile, "http://") || strings.HasPrefix(fp.LocalFile, "https://"))
+}
+
+// spoolSource streams a stdin or URL source into the staging dir and returns the patch
+// rewritten to read the spooled file, so it goes through the same pipeline as local files.
+func (c *CFS3Config) spoolSource(i int, fp FilePatch) (FilePatch, error) {
+	var src io.Reader
+	if fp.isStdin() {
+		src = os.Stdin
+	} else {
+		client := &http.Client{Timeout: vars.SOURCE_FETCH_TIMEOUT}
+		resp, err := client.Get(fp.LocalFile)
+		if err != nil {
+			return fp, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fp, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+		}
+		if resp.ContentLength > vars.MAX_ASSET_SIZE {
+			return fp, fmt.Errorf("content is %d bytes, exceeds maximum allowed %d bytes", resp.ContentLength, vars.MAX_ASSET_SIZE)
+		}
+		if fp.Name == "" {
+			fp.Name = downloadName(resp)
+		}
+		src = resp.Body
+	}
+
+	// Spool below the staging dir so a source can never shadow the generated _headers.
+	dir := filepath.Join(c.stagingDir, "sources")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fp, err
+	}
+	dest := filepath.Join(dir, strconv.Itoa(i)+path.Ext(fp.Name))
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return fp, err
+	}
+	defer out.Close()
+
+	n, err := io.Copy(out, io.LimitReader(src, vars.MAX_ASSET_SIZE+1))
+	if err != nil {
+		return fp, err
+	}
+	if n > vars.MAX_ASSET_SIZE {
+		return fp, fmt.Errorf("content exceeds maximum allowed %d bytes", vars.MAX_ASSET_SIZE)
+	}
+	if err := out.Close(); err != nil {
+		return fp, err
+	}
+
+	fp.LocalFile = dest
+	fp.Temporary = true
+	return fp, nil
+}
+
+// downloadName picks the original file name of a download from its Content-Disposition
+// header, falling back to the last segment of the (final, post-redirect) URL path.
+func downloadName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := path.Base(filepath.ToSlash(params["filename"])); name != "." && name != "/" {
+			return name
+		}
+	}
+
+	if name := path.Base(resp.Request.URL.Path); name != "." && name != "/" {
+		return name
+	}
+	return "download"
+}
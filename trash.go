@@ -0,0 +1,80 @@
+package cfs3
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Hack-Nocturne/cfs3/vars"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// modeUndelete is recorded for deployments made by Undelete.
+const modeUndelete CFS3Mode = "undelete"
+
+// TrashRetention returns how long removed objects stay in the trash.
+func (c *CFS3Config) TrashRetention() time.Duration {
+	if c.TrashRetentionDays > 0 {
+		return time.Duration(c.TrashRetentionDays) * 24 * time.Hour
+	}
+	return vars.TRASH_RETENTION
+}
+
+// Undelete takes objects out of the trash and deploys them again. Their assets are referenced
+// by hash, so this fails with utils.ErrAssetUnavailable once Cloudflare has dropped them.
+func (c *CFS3Config) Undelete(ids []int64) (*ApplyResult, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("no object IDs to undelete")
+	}
+	ids = uniqueIDs(ids)
+
+	trashed, err := worker.FetchTrashedObjects(c.ProjectName, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching trashed objects: %w", err)
+	}
+	if len(trashed) != len(ids) {
+		return nil, fmt.Errorf("only %d of %d objects are in the trash of %s", len(trashed), len(ids), c.ProjectName)
+	}
+
+	meta, err := worker.FetchAllMeta(c.ProjectName)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching existing meta: %w", err)
+	}
+	fileNames := make(map[string]string, len(trashed))
+	for relPath, container := range worker.ObjectMap(trashed) {
+		meta[relPath] = container
+	}
+	for _, obj := range trashed {
+		fileNames[obj.RelPath] = obj.Name
+	}
+
+	deployResp, err := c.deployHashes(modeUndelete, meta, fileNames)
+	if err != nil {
+		return nil, err
+	}
+
+	versionIds, err := worker.UndeleteObjects(c.ProjectName, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failure undeleting objects: %w", err)
+	}
+
+	objects, err := worker.FetchObjectsByIDs(c.ProjectName, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching undeleted objects: %w", err)
+	}
+
+	c.recordDeploymentObjects(deployResp.ID, objectIDs(objects), nil, versionIds)
+
+	return &ApplyResult{DeploymentID: deployResp.ID, URL: deployResp.URL, Objects: objects}, nil
+}
+
+// PurgeTrash permanently deletes trashed objects older than the retention period, or every
+// trashed object when all is set. It returns how many objects were deleted.
+func (c *CFS3Config) PurgeTrash(all bool) (int64, error) {
+	before := time.Now().Add(-c.TrashRetention())
+	if all {
+		before = time.Now()
+	}
+
+	return worker.PurgeTrashedObjects(c.ProjectName, before)
+}
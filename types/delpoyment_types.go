@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"io/fs"
+	"time"
+)
 
 type DeploymentTriggerMetadata struct {
 	Branch        string `json:"branch"`
@@ -66,4 +69,17 @@ type PagesDeployOptions struct {
 	ProjectName string // Cloudflare Pages project name
 	Branch      string // branch name (if empty, assumed production)
 	SkipCaching bool   // whether to skip caching
+
+	Concurrency       int   // maximum concurrent bucket uploads (0 uses the default)
+	MaxBytesPerSecond int64 // combined upload bandwidth cap (0 disables it)
+
+	// Files maps remote relative paths to local source files. When set, assets are taken
+	// from here instead of walking Directory, which then only supplies _headers and friends.
+	Files map[string]string
+	// Assets maps remote relative paths to files whose content is provided by Asset.Open.
+	Assets map[string]Asset
+	// FS, when set, is used instead of the local disk: Directory is a path inside it.
+	FS fs.FS
+	// Existing holds already deployed assets (referenced by hash) to keep in the manifest.
+	Existing map[string]FileContainer
 }
@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"time"
 )
 
 type BuildConfig struct {
@@ -17,6 +18,7 @@ type BuildConfig struct {
 type APIError struct {
 	StatusCode int
 	Message    string
+	RetryAfter time.Duration // parsed from the Retry-After header, if any
 }
 
 func (e *APIError) Error() string {
@@ -1,5 +1,7 @@
 package types
 
+import "io"
+
 // UploadPayloadFile represents a file upload payload.
 type UploadPayloadFile struct {
 	Key      string `json:"key"`
@@ -16,6 +18,17 @@ type FileContainer struct {
 	ContentType string
 	SizeInBytes int64
 	Hash        string
+
+	// Open provides the content of files that don't live on the local disk; when nil, Path is read.
+	Open func() (io.ReadCloser, error) `json:"-"`
+}
+
+// Asset is a file to deploy whose content does not come from a local path,
+// e.g. an fs.FS entry or an in-memory reader.
+type Asset struct {
+	Size int64
+	Open func() (io.ReadCloser, error)
+	Hash string // precomputed asset hash, computed from Open when empty
 }
 
 // UploadArgs holds parameters for the upload function.
@@ -25,6 +38,9 @@ type UploadArgs struct {
 	AccountId   string
 	ProjectName string
 	SkipCaching bool
+
+	Concurrency       int   // maximum concurrent bucket uploads (0 uses the default)
+	MaxBytesPerSecond int64 // combined upload bandwidth cap across all buckets (0 disables it)
 }
 
 // Represents the response from the upload API.
@@ -4,9 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"mime/multipart"
 	"os"
-	"path/filepath"
+	"path"
 	"time"
 
 	"github.com/Hack-Nocturne/cfs3/types"
@@ -14,8 +15,10 @@ import (
 
 // Deploy publishes the directory to Cloudflare Pages by performing the following steps:
 //  1. Reads optional configuration files (_headers, _redirects, _routes.json, _worker.js)
+//     from the directory, or from the directory inside options.FS when set.
 //  2. Fetches project info from Cloudflare.
-//  3. Validates the directory and uploads static assets to generate a manifest.
+//  3. Validates the source files (or the directory), merges in already deployed assets,
+//     and uploads the missing static assets to generate a manifest.
 //  4. Constructs a multipart payload including the manifest and worker bundle.
 //  5. Sends a POST request to the deployment endpoint with retry logic.
 func Deploy(options types.PagesDeployOptions, isPatchMode bool) (*types.DeploymentResponse, map[string]types.FileContainer, error) {
@@ -25,54 +28,62 @@ func Deploy(options types.PagesDeployOptions, isPatchMode bool) (*types.Deployme
 	branch := options.Branch
 	skipCaching := options.SkipCaching
 
-	// Read optional files from the directory.
-	var headersContent, redirectsContent, routesCustomContent, workerJSContent string
-
-	// Read _headers (if exists).
-	headersPath := filepath.Join(directory, "_headers")
-	if data, err := os.ReadFile(headersPath); err == nil {
-		headersContent = string(data)
+	// Read optional files from the directory, on the local disk unless options.FS is set.
+	fsys, root := options.FS, path.Clean(directory)
+	if fsys == nil {
+		fsys, root = os.DirFS(directory), "."
 	}
-
-	// Read _redirects (if exists).
-	redirectsPath := filepath.Join(directory, "_redirects")
-	if data, err := os.ReadFile(redirectsPath); err == nil {
-		redirectsContent = string(data)
+	readOptional := func(name string) string {
+		if data, err := fs.ReadFile(fsys, path.Join(root, name)); err == nil {
+			return string(data)
+		}
+		return ""
 	}
 
-	// Read _routes.json (if exists).
-	routesPath := filepath.Join(directory, "_routes.json")
-	if data, err := os.ReadFile(routesPath); err == nil {
-		routesCustomContent = string(data)
-	}
+	headersContent := readOptional("_headers")
+	redirectsContent := readOptional("_redirects")
+	routesCustomContent := readOptional("_routes.json")
 
 	// Process _worker.js: if it is a directory, try reading an entry file (e.g. index.js),
 	// otherwise read the file content.
-	workerPath := filepath.Join(directory, "_worker.js")
-	if fi, err := os.Stat(workerPath); err == nil {
+	var workerJSContent string
+	if fi, err := fs.Stat(fsys, path.Join(root, "_worker.js")); err == nil {
 		if fi.IsDir() {
-			indexPath := filepath.Join(workerPath, "index.js")
-			if data, err := os.ReadFile(indexPath); err == nil {
-				workerJSContent = string(data)
-			}
+			workerJSContent = readOptional("_worker.js/index.js")
 		} else {
-			if data, err := os.ReadFile(workerPath); err == nil {
-				workerJSContent = string(data)
-			}
+			workerJSContent = readOptional("_worker.js")
 		}
 	}
 
 	// Fetch project info from Cloudflare.
-	projectUrl := fmt.Sprintf("/accounts/%s/pages/projects/%s", accountId, projectName)
-	if _, err := fetchResult[types.ProjectResponse](projectUrl, "GET", nil, nil); err != nil {
+	if _, err := FetchProject(accountId, projectName); err != nil {
 		return nil, nil, fmt.Errorf("failed to fetch project info: %v", err)
 	}
 
-	// Validate the directory and get a file map.
-	fileMap, err := validate(directory, isPatchMode)
+	// Validate the source files (or the directory) and get a file map.
+	var fileMap map[string]types.FileContainer
+	var err error
+	switch {
+	case options.Files != nil || options.Assets != nil:
+		fileMap, err = validateSources(options.Files, options.Assets)
+	case options.FS != nil:
+		fileMap, err = validateFS(options.FS, root, isPatchMode)
+	default:
+		fileMap, err = validate(directory, isPatchMode)
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("validation error: %v", err)
 	}
+	if fileMap == nil {
+		fileMap = make(map[string]types.FileContainer)
+	}
+
+	// Keep already deployed assets in the manifest; they are matched by hash and never re-uploaded.
+	for relPath, container := range options.Existing {
+		if _, exists := fileMap[relPath]; !exists {
+			fileMap[relPath] = container
+		}
+	}
 
 	// Upload static assets and obtain the manifest.
 	uploadArgs := types.UploadArgs{
@@ -80,6 +91,9 @@ func Deploy(options types.PagesDeployOptions, isPatchMode bool) (*types.Deployme
 		AccountId:   accountId,
 		ProjectName: projectName,
 		SkipCaching: skipCaching,
+
+		Concurrency:       options.Concurrency,
+		MaxBytesPerSecond: options.MaxBytesPerSecond,
 	}
 	manifest, err := upload(uploadArgs)
 	if err != nil {
@@ -158,12 +172,32 @@ func Deploy(options types.PagesDeployOptions, isPatchMode bool) (*types.Deployme
 	var lastErr error
 	// Retry loop with exponential backoff.
 	for attempts := range maxAttempts {
-		if deploymentResponse, err := fetchResult[types.DeploymentResponse](deployURL, "POST", headers, buf.Bytes()); err == nil {
+		deploymentResponse, err := fetchResult[types.DeploymentResponse](deployURL, "POST", headers, buf.Bytes())
+		if err == nil {
 			return &deploymentResponse.Result, fileMap, nil
 		}
 		lastErr = err
-		time.Sleep(time.Duration(1<<attempts) * time.Second)
+
+		delay, retry := retryDelay(err, time.Duration(1<<attempts)*time.Second)
+		if !retry {
+			return nil, nil, fmt.Errorf("deployment rate limited by Cloudflare: %w", err)
+		}
+		time.Sleep(delay)
+	}
+
+	return nil, nil, fmt.Errorf("deployment failed after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// DeployFS is Deploy for a file system other than the local disk, e.g. an embed.FS.
+// options.Directory is the directory inside fsys to deploy and defaults to its root.
+func DeployFS(fsys fs.FS, options types.PagesDeployOptions, isPatchMode bool) (*types.DeploymentResponse, map[string]types.FileContainer, error) {
+	if options.Directory == "" {
+		options.Directory = "."
+	}
+	if !fs.ValidPath(options.Directory) {
+		return nil, nil, fmt.Errorf("invalid directory %q in file system", options.Directory)
 	}
+	options.FS = fsys
 
-	return nil, nil, fmt.Errorf("deployment failed after %d attempts: %v", maxAttempts, lastErr)
+	return Deploy(options, isPatchMode)
 }
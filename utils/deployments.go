@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Hack-Nocturne/cfs3/types"
+)
+
+// FetchLatestDeployment returns the newest production deployment of the project.
+func FetchLatestDeployment(accountId, projectName string) (*types.DeploymentResponse, error) {
+	deploymentsUrl := fmt.Sprintf("/accounts/%s/pages/projects/%s/deployments?env=production", accountId, projectName)
+	resp, err := fetchResult[[]types.DeploymentResponse](deploymentsUrl, "GET", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Result) == 0 {
+		return nil, fmt.Errorf("project %q has no production deployments", projectName)
+	}
+
+	return &resp.Result[0], nil
+}
+
+// FetchDeployment returns a single deployment of the project.
+func FetchDeployment(accountId, projectName, deploymentId string) (*types.DeploymentResponse, error) {
+	deploymentUrl := fmt.Sprintf("/accounts/%s/pages/projects/%s/deployments/%s", accountId, projectName, deploymentId)
+	resp, err := fetchResult[types.DeploymentResponse](deploymentUrl, "GET", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp.Result, nil
+}
+
+// FetchDeploymentManifest returns the asset manifest of a deployment, keyed by relative path
+// (without the leading slash used on upload) with asset hashes as values.
+// The manifest endpoint backs the dashboard's asset browser and is not part of Cloudflare's
+// documented API, so it is only ever called from here.
+func FetchDeploymentManifest(accountId, projectName, deploymentId string) (map[string]string, error) {
+	manifestUrl := fmt.Sprintf("/accounts/%s/pages/projects/%s/deployments/%s/manifest", accountId, projectName, deploymentId)
+	resp, err := fetchResult[map[string]string](manifestUrl, "GET", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := make(map[string]string, len(resp.Result))
+	for relPath, hash := range resp.Result {
+		manifest[strings.TrimPrefix(relPath, "/")] = hash
+	}
+
+	return manifest, nil
+}
+
+// RollbackDeployment makes a previous deployment the live production deployment again.
+func RollbackDeployment(accountId, projectName, deploymentId string) (*types.DeploymentResponse, error) {
+	rollbackUrl := fmt.Sprintf("/accounts/%s/pages/projects/%s/deployments/%s/rollback", accountId, projectName, deploymentId)
+	resp, err := fetchResult[types.DeploymentResponse](rollbackUrl, "POST", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp.Result, nil
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zeebo/blake3"
+)
+
+// assetHasher computes the wrangler-compatible asset hash incrementally.
+// Written content is streamed through a base64 encoder straight into Blake3.
+type assetHasher struct {
+	hasher  *blake3.Hasher
+	encoder io.WriteCloser
+	ext     string
+}
+
+func newAssetHasher(ext string) *assetHasher {
+	hasher := blake3.New()
+	return &assetHasher{
+		hasher:  hasher,
+		encoder: base64.NewEncoder(base64.StdEncoding, hasher),
+		ext:     ext,
+	}
+}
+
+func (h *assetHasher) Write(p []byte) (int, error) {
+	return h.encoder.Write(p)
+}
+
+// Sum finalizes the hash. The hasher must not be written to afterwards.
+func (h *assetHasher) Sum() (string, error) {
+	// Flush any partially encoded block (and its padding) before appending the extension.
+	if err := h.encoder.Close(); err != nil {
+		return "", err
+	}
+	h.hasher.WriteString(h.ext)
+
+	return hex.EncodeToString(h.hasher.Sum(nil))[:32], nil
+}
+
+// HashAsset computes the wrangler-compatible asset hash of r: the first 32 hex characters of
+// Blake3(base64(content) + extension). The content is streamed through the base64 encoder
+// straight into the hasher, so no full in-memory copy of the file is ever made.
+// ext is the file extension without the leading dot.
+func HashAsset(r io.Reader, ext string) (string, error) {
+	h := newAssetHasher(ext)
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+
+	return h.Sum()
+}
+
+// HashAssetFile opens the file at path and computes its asset hash using the file's own extension.
+func HashAssetFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return HashAsset(f, strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// HashReader computes the SHA-1 and asset hash of r in a single pass.
+func HashReader(r io.Reader, ext string) (FileHashes, error) {
+	sha1Hasher := sha1.New()
+	h := newAssetHasher(ext)
+	if _, err := io.Copy(io.MultiWriter(sha1Hasher, h), r); err != nil {
+		return FileHashes{}, err
+	}
+
+	blake3Hex, err := h.Sum()
+	if err != nil {
+		return FileHashes{}, err
+	}
+
+	return FileHashes{
+		SHA1:   hex.EncodeToString(sha1Hasher.Sum(nil)),
+		Blake3: blake3Hex,
+	}, nil
+}
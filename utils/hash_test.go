@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/zeebo/blake3"
+)
+
+// legacyAssetHash is the in-memory implementation HashAsset replaced, kept as the reference.
+func legacyAssetHash(content []byte, ext string) string {
+	sum := blake3.Sum256([]byte(base64.StdEncoding.EncodeToString(content) + ext))
+	return hex.EncodeToString(sum[:])[:32]
+}
+
+func TestHashAssetGolden(t *testing.T) {
+	tests := []struct {
+		content string
+		ext     string
+		want    string
+	}{
+		{"", "", "af1349b9f5f9a1a6a0404dea36dcc949"}, // Blake3 of the empty string
+		{"", "html", "bc88d1b19523ba52d6a2959dd93cf9c8"},
+		{"a", "txt", "bdb4c5362de6440eb0e07574e4c76153"},
+		{"ab", "css", "acb710dd84fd3cb5cbb472ca94560fd0"},
+		{"abc", "js", "49f11b0071eb62c0003bdc4b150dfd6d"},
+		{"<h1>Hello, world!</h1>\n", "html", "0ab81f4d783adeeda857c2949d85f18f"},
+	}
+
+	for _, tt := range tests {
+		got, err := HashAsset(strings.NewReader(tt.content), tt.ext)
+		if err != nil {
+			t.Fatalf("HashAsset(%q, %q): %v", tt.content, tt.ext, err)
+		}
+		if got != tt.want {
+			t.Errorf("HashAsset(%q, %q) = %s, want %s", tt.content, tt.ext, got, tt.want)
+		}
+		if legacy := legacyAssetHash([]byte(tt.content), tt.ext); legacy != tt.want {
+			t.Errorf("legacyAssetHash(%q, %q) = %s, want %s", tt.content, tt.ext, legacy, tt.want)
+		}
+	}
+}
+
+func TestHashAssetMatchesLegacy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// Every length modulo 3 around the base64 block size, and inputs longer than the 32 KiB
+	// buffer io.Copy reads with.
+	sizes := []int{0, 1, 2, 3, 4, 5, 6, 7, 32*1024 - 1, 32 * 1024, 32*1024 + 1, 100_001}
+
+	for _, size := range sizes {
+		content := make([]byte, size)
+		rng.Read(content)
+		want := legacyAssetHash(content, "bin")
+
+		got, err := HashAsset(bytes.NewReader(content), "bin")
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if got != want {
+			t.Errorf("size %d: HashAsset = %s, want %s", size, got, want)
+		}
+
+		// Writes that split base64 blocks must not change the hash.
+		if size < 1024 {
+			got, err = HashAsset(iotest.OneByteReader(bytes.NewReader(content)), "bin")
+			if err != nil {
+				t.Fatalf("size %d, one byte reads: %v", size, err)
+			}
+			if got != want {
+				t.Errorf("size %d, one byte reads: HashAsset = %s, want %s", size, got, want)
+			}
+		}
+	}
+}
+
+func TestHashAssetFile(t *testing.T) {
+	content := []byte("body { color: red; }\n")
+	path := filepath.Join(t.TempDir(), "style.css")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := HashAssetFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := legacyAssetHash(content, "css"); got != want {
+		t.Errorf("HashAssetFile = %s, want %s", got, want)
+	}
+}
+
+func TestHashReader(t *testing.T) {
+	content := bytes.Repeat([]byte("cfs3"), 20_000)
+
+	got, err := HashReader(bytes.NewReader(content), "txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sha := sha1.Sum(content)
+	if want := hex.EncodeToString(sha[:]); got.SHA1 != want {
+		t.Errorf("SHA1 = %s, want %s", got.SHA1, want)
+	}
+	if want := legacyAssetHash(content, "txt"); got.Blake3 != want {
+		t.Errorf("Blake3 = %s, want %s", got.Blake3, want)
+	}
+}
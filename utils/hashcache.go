@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Hack-Nocturne/cfs3/vars"
+)
+
+// FileHashes holds both digests cfs3 needs for a local file: the SHA-1 used to name
+// the remote object and the Blake3 asset hash used by Cloudflare Pages.
+type FileHashes struct {
+	SHA1   string `json:"sha1"`
+	Blake3 string `json:"blake3"`
+}
+
+// hashCacheEntry is valid only as long as the file's size, mtime and inode are unchanged.
+type hashCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Inode   uint64 `json:"inode"`
+	FileHashes
+}
+
+type hashCache struct {
+	mu      sync.Mutex
+	loaded  bool
+	dirty   bool
+	entries map[string]hashCacheEntry
+}
+
+var localHashCache = &hashCache{}
+
+// HashCachePath returns the location of the on-disk hash cache.
+// It can be overridden with the CFS3_CACHE_DIR environment variable.
+func HashCachePath() (string, error) {
+	dir := os.Getenv("CFS3_CACHE_DIR")
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(userDir, "cfs3")
+	}
+
+	return filepath.Join(dir, vars.HASH_CACHE_FILE), nil
+}
+
+// load reads the cache file once. A missing or corrupt cache simply starts empty.
+// Callers must hold c.mu.
+func (c *hashCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.entries = make(map[string]hashCacheEntry)
+
+	cachePath, err := HashCachePath()
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		c.entries = make(map[string]hashCacheEntry)
+	}
+}
+
+// HashFile returns the SHA-1 and Blake3 asset hashes of the file at path. Results are cached
+// on disk keyed by absolute path, size, mtime and inode, so unchanged files are never read again.
+// Call SaveHashCache to persist newly computed entries.
+func HashFile(path string) (FileHashes, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return FileHashes{}, err
+	}
+
+	f, err := os.Open(absPath)
+	if err != nil {
+		return FileHashes{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return FileHashes{}, err
+	}
+	key := hashCacheEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Inode:   fileInode(info),
+	}
+
+	localHashCache.mu.Lock()
+	localHashCache.load()
+	entry, hit := localHashCache.entries[absPath]
+	localHashCache.mu.Unlock()
+
+	if hit && entry.Size == key.Size && entry.ModTime == key.ModTime && entry.Inode == key.Inode {
+		return entry.FileHashes, nil
+	}
+
+	hashes, err := HashReader(f, strings.TrimPrefix(filepath.Ext(absPath), "."))
+	if err != nil {
+		return FileHashes{}, err
+	}
+
+	key.FileHashes = hashes
+	localHashCache.mu.Lock()
+	localHashCache.entries[absPath] = key
+	localHashCache.dirty = true
+	localHashCache.mu.Unlock()
+
+	return hashes, nil
+}
+
+// SaveHashCache writes the hash cache to disk if it changed, dropping entries of files
+// that no longer exist.
+func SaveHashCache() error {
+	localHashCache.mu.Lock()
+	defer localHashCache.mu.Unlock()
+
+	if !localHashCache.dirty {
+		return nil
+	}
+
+	for p := range localHashCache.entries {
+		if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
+			delete(localHashCache.entries, p)
+		}
+	}
+
+	cachePath, err := HashCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		return fmt.Errorf("making dirs for hash cache: %w", err)
+	}
+
+	data, err := json.Marshal(localHashCache.entries)
+	if err != nil {
+		return err
+	}
+
+	// Write through a temp file so a crash never leaves a truncated cache behind. Its name is
+	// unique, concurrent runs must not write into each other's temp file.
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), vars.HASH_CACHE_FILE+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing hash cache: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // a no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing hash cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing hash cache: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("writing hash cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return fmt.Errorf("replacing hash cache: %w", err)
+	}
+
+	localHashCache.dirty = false
+	return nil
+}
+
+// HashCacheStats reports the number of cached entries and the size of the cache file.
+func HashCacheStats() (entries int, sizeInBytes int64, err error) {
+	cachePath, err := HashCachePath()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	localHashCache.mu.Lock()
+	localHashCache.load()
+	entries = len(localHashCache.entries)
+	localHashCache.mu.Unlock()
+
+	if info, statErr := os.Stat(cachePath); statErr == nil {
+		sizeInBytes = info.Size()
+	}
+
+	return entries, sizeInBytes, nil
+}
+
+// ClearHashCache removes every cached entry along with the cache file.
+func ClearHashCache() error {
+	cachePath, err := HashCachePath()
+	if err != nil {
+		return err
+	}
+
+	localHashCache.mu.Lock()
+	defer localHashCache.mu.Unlock()
+
+	localHashCache.loaded = true
+	localHashCache.dirty = false
+	localHashCache.entries = make(map[string]hashCacheEntry)
+
+	if err := os.Remove(cachePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,12 +23,18 @@ var mu sync.Mutex
 // fetchResult makes an HTTP request to the given URL (appended to apiBaseURL)
 // with the specified method, headers and body. It then decodes the JSON response into result.
 func fetchResult[T any](url, method string, headers map[string]string, body []byte) (types.CFResponse[T], error) {
+	return fetchResultFrom[T](url, method, headers, bytes.NewReader(body), int64(len(body)))
+}
+
+// fetchResultFrom behaves like fetchResult but streams the request body from a reader of known size.
+func fetchResultFrom[T any](url, method string, headers map[string]string, body io.Reader, size int64) (types.CFResponse[T], error) {
 	client := &http.Client{}
 	empty := types.CFResponse[T]{}
-	req, err := http.NewRequest(method, vars.API_BASE_URL+url, bytes.NewReader(body))
+	req, err := http.NewRequest(method, vars.API_BASE_URL+url, body)
 	if err != nil {
 		return empty, err
 	}
+	req.ContentLength = size
 
 	if headers == nil {
 		headers = make(map[string]string)
@@ -51,7 +58,11 @@ func fetchResult[T any](url, method string, headers map[string]string, body []by
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		respBody, _ := io.ReadAll(resp.Body)
-		return empty, &types.APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
+		return empty, &types.APIError{
+			StatusCode: resp.StatusCode,
+			Message:    string(respBody),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	respBody, err := io.ReadAll(resp.Body)
@@ -69,6 +80,34 @@ func fetchResult[T any](url, method string, headers map[string]string, body []by
 	return response, nil
 }
 
+// parseRetryAfter understands both forms of the Retry-After header: delay seconds and an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// retryDelay returns how long to wait before retrying after err, given the default backoff.
+// A 429 response's Retry-After takes precedence; if it asks for longer than MAX_RETRY_AFTER
+// (e.g. a daily quota), retrying is pointless and ok is false.
+func retryDelay(err error, backoff time.Duration) (delay time.Duration, ok bool) {
+	var apiErr *types.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == 429 && apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > vars.MAX_RETRY_AFTER {
+			return 0, false
+		}
+		return max(apiErr.RetryAfter, backoff), true
+	}
+	return backoff, true
+}
+
 func logError(errors []string) {
 	mu.Lock()
 	defer mu.Unlock()
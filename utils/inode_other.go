@@ -0,0 +1,10 @@
+//go:build !unix
+
+package utils
+
+import "os"
+
+// fileInode is not available on this platform; size and mtime alone key the hash cache.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dlclark/regexp2"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// schemaURL is the name the schema document is compiled under. Nothing is ever loaded from
+// it, or from any $ref outside the document.
+const schemaURL = "cfs3:///metadata.schema.json"
+
+var schemaPrinter = message.NewPrinter(language.English)
+
+// JSONSchema is a compiled JSON Schema (draft 2020-12 unless $schema names another draft).
+// format is asserted, pattern uses ECMA-262 regular expressions, and $ref may only point
+// inside the document itself.
+type JSONSchema struct {
+	schema *jsonschema.Schema
+}
+
+// SchemaError describes where a value violates a schema. Field is the dotted path of the
+// offending value, empty for the value itself.
+type SchemaError struct {
+	Field   string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ParseJSONSchema compiles a schema document.
+func ParseJSONSchema(data []byte) (*JSONSchema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema JSON: %w", err)
+	}
+
+	c := jsonschema.NewCompiler()
+	c.UseLoader(jsonschema.SchemeURLLoader{}) // no files or URLs
+	c.UseRegexpEngine(ecmaRegexp)
+	c.AssertFormat()
+	if err := c.AddResource(schemaURL, doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	schema, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &JSONSchema{schema: schema}, nil
+}
+
+// Validate checks a decoded JSON value against the schema. Numbers may be float64, any Go
+// integer type or json.Number. A violation is returned as a *SchemaError.
+func (s *JSONSchema) Validate(value any) error {
+	err := s.schema.Validate(value)
+
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	return firstViolation(verr)
+}
+
+// ValidateMetadata checks metadata of any Go shape (e.g. map[string]any built by a caller)
+// against the schema by validating its JSON encoding.
+func (s *JSONSchema) ValidateMetadata(metadata any) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	value, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return s.Validate(value)
+}
+
+// firstViolation picks one leaf of the error tree, the first by field, so the same value
+// always reports the same error.
+func firstViolation(verr *jsonschema.ValidationError) *SchemaError {
+	var leaves []*SchemaError
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			leaves = append(leaves, schemaError(e))
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(verr)
+
+	sort.SliceStable(leaves, func(i, j int) bool {
+		if leaves[i].Field != leaves[j].Field {
+			return leaves[i].Field < leaves[j].Field
+		}
+		return leaves[i].Message < leaves[j].Message
+	})
+	return leaves[0]
+}
+
+// schemaError converts a leaf validation error. Missing and unexpected properties are
+// reported on the property itself rather than on the object holding it.
+func schemaError(e *jsonschema.ValidationError) *SchemaError {
+	location := e.InstanceLocation
+	message := e.ErrorKind.LocalizedString(schemaPrinter)
+
+	switch k := e.ErrorKind.(type) {
+	case *kind.Required:
+		sort.Strings(k.Missing)
+		location, message = append(slices.Clone(location), k.Missing[0]), "is required"
+	case *kind.AdditionalProperties:
+		sort.Strings(k.Properties)
+		location, message = append(slices.Clone(location), k.Properties[0]), "is not allowed"
+	}
+
+	return &SchemaError{Field: fieldPath(location), Message: message}
+}
+
+// fieldPath joins an instance location into a dotted path, array indexes in brackets.
+func fieldPath(location []string) string {
+	var b strings.Builder
+	for _, tok := range location {
+		if isIndex(tok) {
+			b.WriteString("[" + tok + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(tok)
+	}
+	return b.String()
+}
+
+func isIndex(tok string) bool {
+	if tok == "" {
+		return false
+	}
+	for _, r := range tok {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ecmaRegexp compiles pattern and patternProperties as ECMA-262 expressions, as the JSON
+// Schema specification requires, instead of Go's RE2 syntax.
+func ecmaRegexp(s string) (jsonschema.Regexp, error) {
+	re, err := regexp2.Compile(s, regexp2.ECMAScript)
+	if err != nil {
+		return nil, err
+	}
+	// Backtracking can blow up on hostile patterns, a metadata check must not hang.
+	re.MatchTimeout = time.Second
+	return (*ecmaPattern)(re), nil
+}
+
+type ecmaPattern regexp2.Regexp
+
+func (re *ecmaPattern) MatchString(s string) bool {
+	matched, err := (*regexp2.Regexp)(re).MatchString(s)
+	return err == nil && matched
+}
+
+func (re *ecmaPattern) String() string {
+	return (*regexp2.Regexp)(re).String()
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+func TestJSONSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		metadata string
+		field    string // expected SchemaError.Field, unused when valid
+		valid    bool
+	}{
+		{"type ok", `{"type":"object"}`, `{}`, "", true},
+		{"type", `{"properties":{"n":{"type":"integer"}}}`, `{"n":1.5}`, "n", false},
+		{"integer written as float", `{"properties":{"n":{"type":"integer"}}}`, `{"n":2.0}`, "", true},
+		{"enum", `{"properties":{"s":{"enum":["a","b"]}}}`, `{"s":"c"}`, "s", false},
+		{"enum nested numbers compare by value", `{"properties":{"o":{"enum":[{"v":1}]}}}`, `{"o":{"v":1.0}}`, "", true},
+		{"const", `{"properties":{"s":{"const":"x"}}}`, `{"s":"y"}`, "s", false},
+		{"required", `{"required":["title"]}`, `{"tag":"x"}`, "title", false},
+		{"nested required", `{"properties":{"a":{"required":["b"]}}}`, `{"a":{}}`, "a.b", false},
+		{"additionalProperties false", `{"properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"z":2}`, "z", false},
+		{"additionalProperties schema", `{"additionalProperties":{"type":"string"}}`, `{"a":"x","b":3}`, "b", false},
+		{"boolean subschema true", `{"properties":{"a":true}}`, `{"a":[1,"x"]}`, "", true},
+		{"boolean subschema false", `{"properties":{"a":false}}`, `{"a":1}`, "a", false},
+		{"items", `{"properties":{"tags":{"items":{"type":"string"}}}}`, `{"tags":["a",2]}`, "tags[1]", false},
+		{"items in nested objects", `{"properties":{"l":{"items":{"properties":{"id":{"type":"integer"}}}}}}`, `{"l":[{"id":1},{"id":"x"}]}`, "l[1].id", false},
+		{"minItems", `{"properties":{"l":{"minItems":2}}}`, `{"l":[1]}`, "l", false},
+		{"maxItems", `{"properties":{"l":{"maxItems":1}}}`, `{"l":[1,2]}`, "l", false},
+		{"minimum", `{"properties":{"n":{"minimum":1}}}`, `{"n":0}`, "n", false},
+		{"maximum", `{"properties":{"n":{"maximum":1}}}`, `{"n":2}`, "n", false},
+		{"exclusiveMinimum", `{"properties":{"n":{"exclusiveMinimum":1}}}`, `{"n":1}`, "n", false},
+		{"exclusiveMaximum", `{"properties":{"n":{"exclusiveMaximum":1}}}`, `{"n":1}`, "n", false},
+		{"large integers keep precision", `{"properties":{"n":{"maximum":9007199254740993}}}`, `{"n":9007199254740993}`, "", true},
+		{"minLength counts characters", `{"properties":{"s":{"minLength":2}}}`, `{"s":"é"}`, "s", false},
+		{"maxLength", `{"properties":{"s":{"maxLength":2}}}`, `{"s":"abc"}`, "s", false},
+		{"pattern", `{"properties":{"s":{"pattern":"^[a-z]+$"}}}`, `{"s":"A1"}`, "s", false},
+		{"pattern is ECMA-262", `{"properties":{"s":{"pattern":"^(?!tmp-)"}}}`, `{"s":"tmp-1"}`, "s", false},
+		{"format is asserted", `{"properties":{"d":{"format":"date"}}}`, `{"d":"yesterday"}`, "d", false},
+		{"local $ref", `{"$defs":{"id":{"type":"integer"}},"properties":{"id":{"$ref":"#/$defs/id"}}}`, `{"id":"x"}`, "id", false},
+		{"first error by field", `{"properties":{"a":{"type":"string"},"b":{"type":"string"}}}`, `{"b":1,"a":1}`, "a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := ParseJSONSchema([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("ParseJSONSchema: %v", err)
+			}
+			value, err := jsonschema.UnmarshalJSON(strings.NewReader(tt.metadata))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = schema.Validate(value)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("Validate(%s) = %v, want valid", tt.metadata, err)
+				}
+				return
+			}
+
+			var serr *SchemaError
+			if !errors.As(err, &serr) {
+				t.Fatalf("Validate(%s) = %v, want a *SchemaError", tt.metadata, err)
+			}
+			if serr.Field != tt.field {
+				t.Errorf("Validate(%s) field = %q, want %q (%v)", tt.metadata, serr.Field, tt.field, serr)
+			}
+			if serr.Message == "" {
+				t.Errorf("Validate(%s) has no message", tt.metadata)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaErrorMessage(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(`{"required":["title"],"properties":{"tags":{"items":{"type":"string"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = schema.ValidateMetadata(map[string]any{"title": "x", "tags": []any{"a", 2}})
+	if err == nil || !strings.HasPrefix(err.Error(), "tags[1]: ") {
+		t.Errorf("ValidateMetadata = %v, want an error on tags[1]", err)
+	}
+
+	err = schema.ValidateMetadata(map[string]any{})
+	if err == nil || err.Error() != "title: is required" {
+		t.Errorf("ValidateMetadata = %v, want %q", err, "title: is required")
+	}
+}
+
+func TestParseJSONSchemaInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"not JSON", `{`},
+		{"wrong keyword type", `{"type":5}`},
+		{"unknown type", `{"type":"text"}`},
+		{"bad pattern", `{"pattern":"("}`},
+		{"external $ref", `{"$ref":"file:///etc/passwd"}`},
+		{"relative $ref", `{"$ref":"other.json"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseJSONSchema([]byte(tt.schema)); err == nil {
+				t.Errorf("ParseJSONSchema(%s) succeeded, want an error", tt.schema)
+			}
+		})
+	}
+}
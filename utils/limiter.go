@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// adaptiveLimiter bounds the number of concurrent bucket uploads. It follows an
+// additive-increase/multiplicative-decrease scheme: the limit halves whenever Cloudflare
+// answers with 429 or 5xx, and grows by one after a full round of healthy uploads.
+type adaptiveLimiter struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	limit     int
+	max       int
+	active    int
+	successes int
+}
+
+func newAdaptiveLimiter(initial, maxLimit int) *adaptiveLimiter {
+	maxLimit = max(maxLimit, 1)
+	initial = min(max(initial, 1), maxLimit)
+
+	l := &adaptiveLimiter{limit: initial, max: maxLimit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until a slot is available under the current limit.
+func (l *adaptiveLimiter) acquire() {
+	l.mu.Lock()
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+	l.mu.Unlock()
+}
+
+func (l *adaptiveLimiter) release() {
+	l.mu.Lock()
+	l.active--
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// onSuccess ramps the limit up by one once `limit` uploads in a row have succeeded.
+func (l *adaptiveLimiter) onSuccess() {
+	l.mu.Lock()
+	l.successes++
+	if l.successes >= l.limit && l.limit < l.max {
+		l.limit++
+		l.successes = 0
+	}
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// onThrottle halves the limit after a 429 or 5xx response.
+func (l *adaptiveLimiter) onThrottle() {
+	l.mu.Lock()
+	l.limit = max(l.limit/2, 1)
+	l.successes = 0
+	l.mu.Unlock()
+}
+
+// bandwidthLimiter caps the combined throughput of every reader sharing it.
+// Each chunk reserves the next free time slot, so concurrent uploads queue fairly.
+type bandwidthLimiter struct {
+	mu             sync.Mutex
+	bytesPerSecond int64
+	next           time.Time
+}
+
+// newBandwidthLimiter returns nil (no limit) when bytesPerSecond is not positive.
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{bytesPerSecond: bytesPerSecond}
+}
+
+// wait blocks until n more bytes may be sent.
+func (b *bandwidthLimiter) wait(n int) {
+	b.mu.Lock()
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	delay := b.next.Sub(now)
+	b.next = b.next.Add(time.Duration(float64(n) / float64(b.bytesPerSecond) * float64(time.Second)))
+	b.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// throttledChunkSize keeps individual reservations small so the rate stays smooth.
+const throttledChunkSize = 32 * 1024
+
+type throttledReader struct {
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+// throttle wraps r so that reads from it respect the limiter. A nil limiter returns r unchanged.
+func (b *bandwidthLimiter) throttle(r io.Reader) io.Reader {
+	if b == nil {
+		return r
+	}
+	return &throttledReader{r: r, limiter: b}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttledChunkSize {
+		p = p[:throttledChunkSize]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.limiter.wait(n)
+	}
+	return n, err
+}
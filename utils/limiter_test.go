@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiterRampsUpToMax(t *testing.T) {
+	l := newAdaptiveLimiter(2, 4)
+
+	// A full round of `limit` successes grows the limit by one.
+	for _, want := range []int{3, 4} {
+		for range l.limit {
+			l.onSuccess()
+		}
+		if l.limit != want {
+			t.Fatalf("limit = %d, want %d", l.limit, want)
+		}
+	}
+
+	for range 10 {
+		l.onSuccess()
+	}
+	if l.limit != 4 {
+		t.Errorf("limit = %d after reaching the max, want 4", l.limit)
+	}
+}
+
+func TestAdaptiveLimiterThrottleHalves(t *testing.T) {
+	l := newAdaptiveLimiter(8, 8)
+
+	for _, want := range []int{4, 2, 1, 1} {
+		l.onThrottle()
+		if l.limit != want {
+			t.Fatalf("limit = %d, want %d", l.limit, want)
+		}
+	}
+
+	// A throttle resets the success streak.
+	l = newAdaptiveLimiter(4, 8)
+	l.onSuccess()
+	l.onSuccess()
+	l.onThrottle()
+	l.onSuccess()
+	if l.limit != 2 {
+		t.Errorf("limit = %d, want 2", l.limit)
+	}
+}
+
+func TestAdaptiveLimiterBounds(t *testing.T) {
+	tests := []struct {
+		initial, max       int
+		wantLimit, wantMax int
+	}{
+		{0, 0, 1, 1},
+		{6, 3, 3, 3},
+		{-1, 5, 1, 5},
+		{2, 16, 2, 16},
+	}
+
+	for _, tt := range tests {
+		l := newAdaptiveLimiter(tt.initial, tt.max)
+		if l.limit != tt.wantLimit || l.max != tt.wantMax {
+			t.Errorf("newAdaptiveLimiter(%d, %d) = limit %d, max %d, want %d, %d",
+				tt.initial, tt.max, l.limit, l.max, tt.wantLimit, tt.wantMax)
+		}
+	}
+}
+
+func TestAdaptiveLimiterBlocksAtLimit(t *testing.T) {
+	l := newAdaptiveLimiter(2, 2)
+
+	var mu sync.Mutex
+	active, peak := 0, 0
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.acquire()
+			mu.Lock()
+			active++
+			peak = max(peak, active)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+			l.release()
+		}()
+	}
+	wg.Wait()
+
+	if peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak)
+	}
+}
+
+func TestBandwidthLimiterDisabled(t *testing.T) {
+	if b := newBandwidthLimiter(0); b != nil {
+		t.Fatalf("newBandwidthLimiter(0) = %v, want nil", b)
+	}
+
+	var b *bandwidthLimiter
+	r := bytes.NewReader(nil)
+	if got := b.throttle(r); got != io.Reader(r) {
+		t.Errorf("nil limiter wrapped the reader")
+	}
+}
+
+func TestBandwidthLimiterRate(t *testing.T) {
+	const rate = 256 * 1024
+	b := newBandwidthLimiter(rate)
+	data := make([]byte, rate/2)
+
+	// Two readers share the budget: 2 * rate/2 bytes take about a second, the first
+	// chunk is sent right away.
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := io.Copy(io.Discard, b.throttle(bytes.NewReader(data)))
+			if err != nil || n != int64(len(data)) {
+				t.Errorf("copied %d bytes, err %v", n, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	if want := time.Second - time.Second*throttledChunkSize/rate; elapsed < want-50*time.Millisecond {
+		t.Errorf("sending %d bytes at %d B/s took %v, want at least %v", 2*len(data), rate, elapsed, want)
+	}
+	if elapsed > 3*time.Second {
+		t.Errorf("sending %d bytes at %d B/s took %v", 2*len(data), rate, elapsed)
+	}
+}
+
+func TestThrottledReaderChunks(t *testing.T) {
+	b := newBandwidthLimiter(1 << 30)
+	r := b.throttle(bytes.NewReader(make([]byte, 3*throttledChunkSize)))
+
+	buf := make([]byte, 2*throttledChunkSize)
+	n, err := r.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != throttledChunkSize {
+		t.Errorf("Read returned %d bytes, want at most %d", n, throttledChunkSize)
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Hack-Nocturne/cfs3/types"
+)
+
+var projectURLs sync.Map // project name -> base URL
+
+// FetchProject returns the Cloudflare Pages project details.
+func FetchProject(accountId, projectName string) (*types.ProjectResponse, error) {
+	projectUrl := fmt.Sprintf("/accounts/%s/pages/projects/%s", accountId, projectName)
+	resp, err := fetchResult[types.ProjectResponse](projectUrl, "GET", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp.Result, nil
+}
+
+// ProjectURL returns the public base URL of the project's production deployment
+// (e.g. https://my-project.pages.dev), without a trailing slash. Results are cached.
+func ProjectURL(accountId, projectName string) (string, error) {
+	if cached, ok := projectURLs.Load(projectName); ok {
+		return cached.(string), nil
+	}
+
+	project, err := FetchProject(accountId, projectName)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch project info: %w", err)
+	}
+	if project.Subdomain == "" {
+		return "", fmt.Errorf("project %q has no pages.dev subdomain", projectName)
+	}
+
+	baseURL := "https://" + strings.TrimSuffix(project.Subdomain, "/")
+	projectURLs.Store(projectName, baseURL)
+
+	return baseURL, nil
+}
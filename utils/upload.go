@@ -1,11 +1,15 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +17,10 @@ import (
 	"github.com/Hack-Nocturne/cfs3/vars"
 )
 
+// ErrAssetUnavailable is returned when a file referenced only by hash is no longer held by
+// Cloudflare Pages, so there is nothing to upload it from.
+var ErrAssetUnavailable = errors.New("asset no longer held by Cloudflare Pages")
+
 // upload processes file uploads by first determining missing file hashes,
 // bucketing files, and concurrently uploading each bucket.
 func upload(args types.UploadArgs) (map[string]string, error) {
@@ -82,7 +90,11 @@ func upload(args types.UploadArgs) (map[string]string, error) {
 		missingResp, err := fetchResult[[]string]("/pages/assets/check-missing", "POST", headers, payloadBytes)
 		if err != nil {
 			if attempts < vars.MAX_CHECK_MISSING_ATTEMPTS {
-				time.Sleep(time.Second * time.Duration(1<<attempts))
+				delay, retry := retryDelay(err, time.Second*time.Duration(1<<attempts))
+				if !retry {
+					return nil, err
+				}
+				time.Sleep(delay)
 				attempts++
 				// If unauthorized or JWT expired, refresh the token.
 				if apiErr, ok := err.(*types.APIError); ok && apiErr.StatusCode == 401 {
@@ -112,11 +124,18 @@ func upload(args types.UploadArgs) (map[string]string, error) {
 	for _, h := range missingHashes {
 		missingSet[h] = true
 	}
+	var unavailable []string
 	for _, file := range files {
 		if missingSet[file.Hash] {
+			if file.Path == "" && file.Open == nil {
+				unavailable = append(unavailable, file.Hash)
+			}
 			sortedFiles = append(sortedFiles, file)
 		}
 	}
+	if len(unavailable) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrAssetUnavailable, strings.Join(unavailable, ", "))
+	}
 	// Sort descending by file size.
 	sort.Slice(sortedFiles, func(i, j int) bool {
 		return sortedFiles[i].SizeInBytes > sortedFiles[j].SizeInBytes
@@ -128,8 +147,12 @@ func upload(args types.UploadArgs) (map[string]string, error) {
 		RemainingSize int64
 	}
 	var buckets []Bucket
-	// Start with a few buckets so that even small projects benefit from concurrency.
-	for range vars.BULK_UPLOAD_CONCURRENCY {
+	maxConcurrency := args.Concurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = vars.MAX_UPLOAD_CONCURRENCY
+	}
+	// Start with one bucket per upload slot so that even small projects benefit from concurrency.
+	for range maxConcurrency {
 		buckets = append(buckets, Bucket{
 			Files:         []types.FileContainer{},
 			RemainingSize: vars.MAX_BUCKET_SIZE,
@@ -161,8 +184,10 @@ func upload(args types.UploadArgs) (map[string]string, error) {
 	counter := len(args.FileMap) - len(sortedFiles)
 	incUpTo(args.ProjectName, counter, len(args.FileMap))
 
-	// Use a semaphore to limit concurrency.
-	sem := make(chan struct{}, vars.BULK_UPLOAD_CONCURRENCY)
+	// Concurrency adapts to how Cloudflare responds, and all buckets share one bandwidth budget.
+	// It starts below the ceiling, there is nothing to ramp up to otherwise.
+	limiter := newAdaptiveLimiter(min(vars.BULK_UPLOAD_CONCURRENCY, max(maxConcurrency/2, 1)), maxConcurrency)
+	bandwidth := newBandwidthLimiter(args.MaxBytesPerSecond)
 	var wg sync.WaitGroup
 	var uploadErr error
 	var mu sync.Mutex
@@ -178,12 +203,16 @@ func upload(args types.UploadArgs) (map[string]string, error) {
 			attempts := 0
 			gatewayErrors := 0
 
-			var doUpload func() error
-			doUpload = func() error {
+			// sendBucket holds an upload slot only while building and sending the payload,
+			// so buckets that are backing off don't count against the concurrency limit.
+			sendBucket := func() error {
+				limiter.acquire()
+				defer limiter.release()
+
 				// Build the payload.
 				payload := make([]types.UploadPayloadFile, len(bucket.Files))
 				for i, file := range bucket.Files {
-					data, err := os.ReadFile(file.Path)
+					data, err := readContent(file)
 					if err != nil {
 						return err
 					}
@@ -202,12 +231,32 @@ func upload(args types.UploadArgs) (map[string]string, error) {
 					"Authorization": "Bearer " + jwt,
 				}
 
-				_, err = fetchResult[types.UploadResponse]("/pages/assets/upload", "POST", headers, payloadBytes)
+				_, err = fetchResultFrom[types.UploadResponse](
+					"/pages/assets/upload",
+					"POST",
+					headers,
+					bandwidth.throttle(bytes.NewReader(payloadBytes)),
+					int64(len(payloadBytes)),
+				)
+				return err
+			}
+
+			var doUpload func() error
+			doUpload = func() error {
+				err := sendBucket()
 				if err != nil {
 					if attempts < vars.MAX_UPLOAD_ATTEMPTS {
-						time.Sleep(time.Second * time.Duration(1<<attempts))
+						delay, retry := retryDelay(err, time.Second*time.Duration(1<<attempts))
+						if !retry {
+							return err
+						}
+						time.Sleep(delay)
 						attempts++
 						if apiErr, ok := err.(*types.APIError); ok {
+							// Back off the shared concurrency on rate limiting and server errors.
+							if apiErr.StatusCode == 429 || apiErr.StatusCode >= 500 {
+								limiter.onThrottle()
+							}
 							// Check for gateway errors (e.g. 502, 503, 504)
 							switch apiErr.StatusCode {
 							case 502, 503, 504:
@@ -230,13 +279,11 @@ func upload(args types.UploadArgs) (map[string]string, error) {
 					}
 					return err
 				}
+				limiter.onSuccess()
 				return nil
 			}
 
-			// Limit concurrency per bucket.
-			sem <- struct{}{}
 			err := doUpload()
-			<-sem
 			if err != nil {
 				mu.Lock()
 				if uploadErr == nil {
@@ -307,3 +354,18 @@ func upload(args types.UploadArgs) (map[string]string, error) {
 	}
 	return manifest, nil
 }
+
+// readContent returns the content of a file, either from its Open func or from the local disk.
+func readContent(file types.FileContainer) ([]byte, error) {
+	if file.Open == nil {
+		return os.ReadFile(file.Path)
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
@@ -1,10 +1,12 @@
 package utils
 
 import (
-	"encoding/base64"
-	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -14,7 +16,6 @@ import (
 	"github.com/Hack-Nocturne/cfs3/types"
 	"github.com/Hack-Nocturne/cfs3/vars"
 	"github.com/bmatcuk/doublestar/v4"
-	"github.com/zeebo/blake3"
 )
 
 // Ignore patterns (similar to the Node.js ignore list)
@@ -29,8 +30,8 @@ var ignorePatterns = []string{
 	"**/.git",
 }
 
-// shouldIgnore checks if a given relative path matches one of the ignore patterns.
-func shouldIgnore(relPath string) bool {
+// ShouldIgnore checks if a given relative path matches one of the ignore patterns.
+func ShouldIgnore(relPath string) bool {
 	// Ensure a consistent (unix-style) path separator.
 	relPath = filepath.ToSlash(relPath)
 	for _, pattern := range ignorePatterns {
@@ -48,6 +49,9 @@ type fileTask struct {
 	fullPath  string
 	size      int64
 	extension string
+
+	open func() (io.ReadCloser, error) // set for files that are not on the local disk
+	hash string                        // precomputed asset hash, if any
 }
 
 // validate walks the directory, processes files concurrently,
@@ -63,8 +67,6 @@ func validate(directory string, isPatchMode bool) (map[string]types.FileContaine
 	}
 
 	startTime := time.Now()
-	var fileMap map[string]types.FileContainer
-
 	defer func() {
 		duration := time.Since(startTime).Seconds()
 		fmt.Printf("Validation took %.2f seconds\n", duration)
@@ -85,7 +87,7 @@ func validate(directory string, isPatchMode bool) (map[string]types.FileContaine
 		relPath = filepath.ToSlash(relPath)
 
 		// Skip ignored files/directories.
-		if shouldIgnore(relPath) {
+		if ShouldIgnore(relPath) {
 			if d.IsDir() {
 				return filepath.SkipDir
 			}
@@ -124,6 +126,133 @@ func validate(directory string, isPatchMode bool) (map[string]types.FileContaine
 		return nil, err
 	}
 
+	return hashTasks(tasks)
+}
+
+// validateFS walks root inside fsys like validate does for a local directory.
+func validateFS(fsys fs.FS, root string, isPatchMode bool) (map[string]types.FileContainer, error) {
+	if !isPatchMode {
+		return nil, nil
+	}
+
+	startTime := time.Now()
+	defer func() {
+		duration := time.Since(startTime).Seconds()
+		fmt.Printf("Validation took %.2f seconds\n", duration)
+	}()
+
+	root = path.Clean(root)
+	var tasks []fileTask
+	err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+
+		relPath := p
+		if root != "." {
+			relPath = strings.TrimPrefix(p, root+"/")
+		}
+
+		// Skip ignored files/directories.
+		if ShouldIgnore(relPath) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		// Check file size.
+		if info.Size() > vars.MAX_ASSET_SIZE {
+			return fmt.Errorf("file %s is %d bytes, exceeds maximum allowed %d bytes", relPath, info.Size(), vars.MAX_ASSET_SIZE)
+		}
+
+		tasks = append(tasks, fileTask{
+			relative:  relPath,
+			fullPath:  p,
+			size:      info.Size(),
+			extension: strings.TrimPrefix(path.Ext(p), "."),
+			open:      func() (io.ReadCloser, error) { return fsys.Open(p) },
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hashTasks(tasks)
+}
+
+// validateSources hashes an explicit set of files keyed by their remote relative path, without
+// copying them anywhere first: local files by path and assets through their Open func.
+// Asset hashes always use the extension of the remote path, so they can be verified against
+// the deployed file.
+func validateSources(files map[string]string, assets map[string]types.Asset) (map[string]types.FileContainer, error) {
+	startTime := time.Now()
+	defer func() {
+		duration := time.Since(startTime).Seconds()
+		fmt.Printf("Validation took %.2f seconds\n", duration)
+	}()
+
+	tasks := make([]fileTask, 0, len(files)+len(assets))
+	for relPath, localPath := range files {
+		info, err := os.Stat(localPath)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("%s is a directory", localPath)
+		}
+
+		// Check file size.
+		if info.Size() > vars.MAX_ASSET_SIZE {
+			return nil, fmt.Errorf("file %s is %d bytes, exceeds maximum allowed %d bytes", relPath, info.Size(), vars.MAX_ASSET_SIZE)
+		}
+
+		tasks = append(tasks, fileTask{
+			relative:  filepath.ToSlash(relPath),
+			fullPath:  localPath,
+			size:      info.Size(),
+			extension: strings.TrimPrefix(path.Ext(relPath), "."),
+		})
+	}
+
+	for relPath, asset := range assets {
+		if asset.Open == nil {
+			return nil, fmt.Errorf("asset %s has no content", relPath)
+		}
+		if asset.Size > vars.MAX_ASSET_SIZE {
+			return nil, fmt.Errorf("file %s is %d bytes, exceeds maximum allowed %d bytes", relPath, asset.Size, vars.MAX_ASSET_SIZE)
+		}
+
+		tasks = append(tasks, fileTask{
+			relative:  filepath.ToSlash(relPath),
+			fullPath:  relPath,
+			size:      asset.Size,
+			extension: strings.TrimPrefix(path.Ext(relPath), "."),
+			open:      asset.Open,
+			hash:      asset.Hash,
+		})
+	}
+
+	return hashTasks(tasks)
+}
+
+// hashTasks hashes the given files concurrently and returns a map of relative paths to FileContainer.
+func hashTasks(tasks []fileTask) (map[string]types.FileContainer, error) {
+	fileMap := make(map[string]types.FileContainer)
+
 	// Check overall file count.
 	if len(tasks) > vars.MAX_ASSET_COUNT {
 		return nil, fmt.Errorf("number of files %d exceeds maximum allowed %d", len(tasks), vars.MAX_ASSET_COUNT)
@@ -131,6 +260,7 @@ func validate(directory string, isPatchMode bool) (map[string]types.FileContaine
 
 	var mu sync.Mutex
 	var wg sync.WaitGroup
+	var hashErrs []error
 
 	workerCount := runtime.NumCPU()
 	tasksChan := make(chan fileTask, len(tasks))
@@ -141,21 +271,13 @@ func validate(directory string, isPatchMode bool) (map[string]types.FileContaine
 		go func() {
 			defer wg.Done()
 			for task := range tasksChan {
-				// Read file contents.
-				data, err := os.ReadFile(task.fullPath)
+				hash, err := task.assetHash()
 				if err != nil {
-					fmt.Printf("Error reading file %s: %v", task.fullPath, err)
+					mu.Lock()
+					hashErrs = append(hashErrs, fmt.Errorf("hashing %s: %w", task.fullPath, err))
+					mu.Unlock()
 					continue
 				}
-				// Encode the file's contents as base64.
-				base64Content := base64.StdEncoding.EncodeToString(data)
-				// Concatenate the base64 content with the file extension.
-				input := base64Content + task.extension
-
-				// Compute Blake3 hash.
-				hashBytes := blake3.Sum256([]byte(input))
-				hexStr := hex.EncodeToString(hashBytes[:])
-				hashFinal := hexStr[:32] // take the first 32 hex characters
 
 				// Determine the MIME type based on the file extension.
 				extWithDot := filepath.Ext(task.relative)
@@ -168,7 +290,8 @@ func validate(directory string, isPatchMode bool) (map[string]types.FileContaine
 					Path:        task.fullPath,
 					ContentType: mimeType,
 					SizeInBytes: task.size,
-					Hash:        hashFinal,
+					Hash:        hash,
+					Open:        task.open,
 				}
 
 				// Protect concurrent map writes.
@@ -186,5 +309,39 @@ func validate(directory string, isPatchMode bool) (map[string]types.FileContaine
 	close(tasksChan)
 	wg.Wait()
 
+	if err := SaveHashCache(); err != nil {
+		fmt.Println("⚠️ Failed to save hash cache:", err)
+	}
+
+	// A file that can't be hashed would silently drop out of the deployment and of D1.
+	if err := errors.Join(hashErrs...); err != nil {
+		return nil, err
+	}
+
 	return fileMap, nil
 }
+
+// assetHash returns the asset hash of the task's file. Unchanged local files are served from
+// the hash cache, others are streamed through the hasher.
+func (t fileTask) assetHash() (string, error) {
+	if t.hash != "" {
+		return t.hash, nil
+	}
+	var rc io.ReadCloser
+	var err error
+	switch {
+	case t.open != nil:
+		rc, err = t.open()
+	case strings.TrimPrefix(filepath.Ext(t.fullPath), ".") == t.extension:
+		hashes, err := HashFile(t.fullPath)
+		return hashes.Blake3, err
+	default:
+		rc, err = os.Open(t.fullPath) // stored under another extension, the cached hash doesn't apply
+	}
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	return HashAsset(rc, t.extension)
+}
@@ -1,15 +1,28 @@
 package vars
 
+import "time"
+
 const (
 	KB_SIZE                    = 1_024
 	API_BASE_URL               = "https://api.cloudflare.com/client/v4"
 	MAX_ASSET_COUNT            = 20_000
 	MAX_ASSET_SIZE             = 25 * KB_SIZE * KB_SIZE
-	BULK_UPLOAD_CONCURRENCY    = 6
+	BULK_UPLOAD_CONCURRENCY    = 6  // concurrent bucket uploads a deployment starts with
+	MAX_UPLOAD_CONCURRENCY     = 16 // default ceiling the upload concurrency ramps up to
 	MAX_BUCKET_FILE_COUNT      = 2_500
 	MAX_BUCKET_SIZE            = 72 * KB_SIZE * KB_SIZE // 72MB * 4/3 (base64) = 96MB (max size of a single request: 100MB)
 	MAX_CHECK_MISSING_ATTEMPTS = 4
 	MAX_UPLOAD_ATTEMPTS        = 8
 	MAX_UPLOAD_GATEWAY_ERRORS  = 4
-	UPLOAD_BASE_DIR            = "cfs3__uploads"
+	MAX_RETRY_AFTER            = 2 * time.Minute // longer Retry-After hints abort instead of waiting
+	DEPLOY_BUDGET_WINDOW       = 24 * time.Hour
+	STAGING_DIR_PATTERN        = "cfs3-staging-*"
+	HASH_CACHE_FILE            = "hash-cache.json"
+	S3_SPOOL_DIR_PATTERN       = "cfs3-s3-*"
+	API_SPOOL_DIR_PATTERN      = "cfs3-api-*"
+	API_KEY_TOUCH_INTERVAL     = time.Minute      // how often the last use of an API key is written to D1 at most
+	SOURCE_FETCH_TIMEOUT       = 10 * time.Minute // whole-download limit for http(s) patch sources
+	PULL_CONCURRENCY           = 8
+	SIDECAR_SUFFIX             = ".cfs3.json"        // metadata file written next to every pulled object
+	TRASH_RETENTION            = 30 * 24 * time.Hour // how long removed objects stay recoverable by default
 )
@@ -0,0 +1,75 @@
+package cfs3
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Hack-Nocturne/cfs3/types"
+	"github.com/Hack-Nocturne/cfs3/utils"
+	"github.com/Hack-Nocturne/cfs3/worker"
+)
+
+// modeRestore is recorded for deployments made by RestoreVersion.
+const modeRestore CFS3Mode = "restore"
+
+// RestoreVersion puts an object version back into the project, at its own path or at
+// relPath when set, and records it as added by c.By. The old hash is re-added to the
+// manifest without a local copy, which only works while Cloudflare Pages still holds the
+// asset; otherwise utils.ErrAssetUnavailable is returned and nothing changes.
+func (c *CFS3Config) RestoreVersion(versionId int64, relPath string) (*ApplyResult, error) {
+	version, err := worker.FetchObjectVersion(versionId)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching version: %w", err)
+	}
+	if version == nil || version.ProjectName != c.ProjectName {
+		return nil, fmt.Errorf("version %d not found in %s", versionId, c.ProjectName)
+	}
+
+	if relPath == "" {
+		relPath = version.RelPath
+	}
+	relPath = strings.Trim(path.Clean("/"+relPath), "/")
+	if relPath == "" {
+		return nil, errors.New("invalid restore path")
+	}
+
+	meta, err := worker.FetchAllMeta(c.ProjectName)
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching existing meta: %w", err)
+	}
+	meta[relPath] = types.FileContainer{
+		ContentType: utils.ExtToMimeType(path.Ext(relPath)),
+		SizeInBytes: max(version.Size, 1),
+		Hash:        version.Hash,
+	}
+
+	deployResp, err := c.deployHashes(modeRestore, meta, map[string]string{relPath: version.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	by := c.By
+	versionIds, err := worker.BulkAddObjects([]worker.Object{{
+		Hash:        version.Hash,
+		RelPath:     relPath,
+		Name:        version.Name,
+		AddedBy:     &by,
+		ProjectName: c.ProjectName,
+		Metadata:    version.Metadata,
+		Size:        version.Size,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := worker.FetchObjectsByPaths(c.ProjectName, []string{relPath})
+	if err != nil {
+		return nil, fmt.Errorf("failure fetching restored object: %w", err)
+	}
+
+	c.recordDeploymentObjects(deployResp.ID, objectIDs(objects), nil, versionIds)
+
+	return &ApplyResult{DeploymentID: deployResp.ID, URL: deployResp.URL, Objects: objects}, nil
+}
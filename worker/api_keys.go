@@ -0,0 +1,38 @@
+package worker
+
+import "time"
+
+func CreateAPIKey(key *APIKey) error {
+	return db.Create(key).Error
+}
+
+// FindActiveAPIKey returns the non-revoked key with the given digest, or nil.
+func FindActiveAPIKey(keyHash string) (*APIKey, error) {
+	var keys []APIKey
+	err := db.Where("key_hash = ? AND revoked_at IS NULL", keyHash).Limit(1).Find(&keys).Error
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+
+	return &keys[0], nil
+}
+
+func ListAPIKeys() ([]APIKey, error) {
+	var keys []APIKey
+	err := db.Order("id ASC").Find(&keys).Error
+
+	return keys, err
+}
+
+// RevokeAPIKey marks a key as revoked; it reports whether an active key was found.
+func RevokeAPIKey(id int64) (bool, error) {
+	res := db.Model(&APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+
+	return res.RowsAffected > 0, res.Error
+}
+
+func TouchAPIKey(id int64) error {
+	return db.Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", time.Now()).Error
+}
@@ -0,0 +1,327 @@
+package worker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// BackupSchemaVersion is the format version written in the header of ExportTables.
+// Version 2 added the promoted metadata keys.
+const BackupSchemaVersion = 2
+
+// backupPageSize is the number of rows read per query while exporting.
+const backupPageSize = 500
+
+// backupModels lists the tables covered by a backup. Rows of metadata_indexes are promoted
+// again on import rather than copied, their generated columns have to be added too.
+var backupModels = []any{&Object{}, &ObjectVersion{}, &Snapshot{}, &SnapshotEntry{}, &Deployment{}, &APIKey{}, &MetadataSchema{}, &MetadataIndex{}}
+
+// BackupHeader is the first line of a backup.
+type BackupHeader struct {
+	SchemaVersion int       `json:"schema_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	Tables        []string  `json:"tables"`
+}
+
+// BackupRow is a line of a backup after the header: one row of a table, keyed by column.
+type BackupRow struct {
+	Table string         `json:"table"`
+	Row   map[string]any `json:"row"`
+}
+
+// ExportTables streams every row of the cfs3 tables (trashed objects included) to w as
+// NDJSON: a BackupHeader line followed by one BackupRow line per row. It returns the number
+// of rows written per table.
+func ExportTables(w io.Writer) (map[string]int, error) {
+	schemas, err := backupSchemas()
+	if err != nil {
+		return nil, err
+	}
+
+	header := BackupHeader{SchemaVersion: BackupSchemaVersion, ExportedAt: time.Now().UTC()}
+	for _, s := range schemas {
+		header.Tables = append(header.Tables, s.Table)
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(schemas))
+	for i, s := range schemas {
+		pk := s.PrioritizedPrimaryField.DBName
+
+		// Page by primary key so rows added meanwhile can't shift pages.
+		var last any
+		for {
+			query := db.Unscoped().Model(backupModels[i]).Order(clause.OrderByColumn{Column: clause.Column{Name: pk}}).Limit(backupPageSize)
+			if last != nil {
+				query = query.Where(clause.Gt{Column: clause.Column{Name: pk}, Value: last})
+			}
+
+			var rows []map[string]any
+			if err := query.Find(&rows).Error; err != nil {
+				return nil, fmt.Errorf("reading %s: %w", s.Table, err)
+			}
+			for _, row := range rows {
+				for col, v := range row {
+					// Generated columns of promoted metadata keys are derived, not data.
+					if s.LookUpField(col) == nil {
+						delete(row, col)
+						continue
+					}
+					if b, ok := v.([]byte); ok {
+						row[col] = string(b)
+					}
+				}
+				if err := enc.Encode(BackupRow{Table: s.Table, Row: row}); err != nil {
+					return nil, err
+				}
+			}
+
+			counts[s.Table] += len(rows)
+			if len(rows) < backupPageSize {
+				break
+			}
+			last = rows[len(rows)-1][pk]
+		}
+	}
+
+	return counts, bw.Flush()
+}
+
+// ImportTables reads a backup written by ExportTables and upserts its rows by primary key,
+// so importing the same backup again is harmless. Objects stored at a path another object
+// already takes in the database are refused, and the backup's metadata keys are promoted.
+// It returns the number of rows imported per table.
+func ImportTables(r io.Reader) (map[string]int, error) {
+	schemas, err := backupSchemas()
+	if err != nil {
+		return nil, err
+	}
+	models := make(map[string]int, len(schemas))
+	for i, s := range schemas {
+		models[s.Table] = i
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var header BackupHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("reading backup header: %w", err)
+	}
+	if header.SchemaVersion < 1 || header.SchemaVersion > BackupSchemaVersion {
+		return nil, fmt.Errorf("unsupported backup schema version %d", header.SchemaVersion)
+	}
+
+	counts := make(map[string]int)
+	var (
+		table    string
+		batch    []map[string]any
+		promoted []string
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		var err error
+		switch table {
+		case "objects":
+			if err = checkObjectPaths(batch); err == nil {
+				err = upsertRows(schemas[models[table]], backupModels[models[table]], batch)
+			}
+		case "metadata_indexes":
+			for _, row := range batch {
+				key, _ := row["key"].(string)
+				promoted = append(promoted, key)
+			}
+		default:
+			err = upsertRows(schemas[models[table]], backupModels[models[table]], batch)
+		}
+		if err != nil {
+			return fmt.Errorf("importing %s: %w", table, err)
+		}
+		counts[table] += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for line := 2; ; line++ {
+		var row BackupRow
+		if err := dec.Decode(&row); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return counts, fmt.Errorf("reading backup line %d: %w", line, err)
+		}
+
+		i, ok := models[row.Table]
+		if !ok {
+			return counts, fmt.Errorf("backup line %d: unknown table %q", line, row.Table)
+		}
+		values, err := columnValues(schemas[i], row.Row)
+		if err != nil {
+			return counts, fmt.Errorf("backup line %d: %w", line, err)
+		}
+
+		if row.Table != table || len(batch) >= max(1, maxInParams/len(values)) {
+			if err := flush(); err != nil {
+				return counts, err
+			}
+			table = row.Table
+		}
+		batch = append(batch, values)
+	}
+
+	if err := flush(); err != nil {
+		return counts, err
+	}
+	for _, key := range promoted {
+		if _, err := PromoteMetadataKey(key); err != nil {
+			return counts, fmt.Errorf("promoting metadata key %q: %w", key, err)
+		}
+	}
+	if counts["objects"] > 0 {
+		if err := RebuildSearchIndex(); err != nil {
+			return counts, fmt.Errorf("rebuilding search index: %w", err)
+		}
+	}
+	return counts, nil
+}
+
+// checkObjectPaths fails if an object row of a backup would take a path that another object
+// of the database is stored at. The IDs of objects are referenced by versions, snapshots and
+// deployments, so the backup's row can't take over the existing one; re-importing the same
+// rows is fine.
+func checkObjectPaths(rows []map[string]any) error {
+	byProject := make(map[string]map[string]any) // project -> rel_path -> backup id
+	for _, row := range rows {
+		projName, _ := row["project_name"].(string)
+		relPath, _ := row["rel_path"].(string)
+		if byProject[projName] == nil {
+			byProject[projName] = make(map[string]any)
+		}
+		byProject[projName][relPath] = row["id"]
+	}
+
+	for projName, paths := range byProject {
+		var existing []Object
+		err := db.Unscoped().
+			Where("project_name = ? AND rel_path IN ?", projName, slices.Collect(maps.Keys(paths))).
+			Find(&existing).Error
+		if err != nil {
+			return err
+		}
+		for _, obj := range existing {
+			if id, ok := paths[obj.RelPath].(int64); !ok || id != obj.ID {
+				return fmt.Errorf("%s/%s is already stored as object %d, import into an empty database", projName, obj.RelPath, obj.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// upsertRows inserts rows into the table of model, replacing rows with the same primary key.
+// Every row must have the same columns.
+func upsertRows(s *schema.Schema, model any, rows []map[string]any) error {
+	pk := s.PrioritizedPrimaryField.DBName
+
+	var update []string
+	for col := range rows[0] {
+		if col != pk {
+			update = append(update, col)
+		}
+	}
+	slices.Sort(update)
+
+	return db.Model(model).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: pk}},
+		DoUpdates: clause.AssignmentColumns(update),
+	}).Create(&rows).Error
+}
+
+// columnValues checks a backup row against the table schema and converts its JSON values
+// back to the column types: numbers to int64 or float64 and timestamps to time.Time.
+func columnValues(s *schema.Schema, row map[string]any) (map[string]any, error) {
+	if _, ok := row[s.PrioritizedPrimaryField.DBName]; !ok {
+		return nil, fmt.Errorf("row of %s has no %s", s.Table, s.PrioritizedPrimaryField.DBName)
+	}
+
+	values := make(map[string]any, len(row))
+	for col, v := range row {
+		field := s.LookUpField(col)
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("%s has no column %q", s.Table, col)
+		}
+
+		switch v := v.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				values[col] = n
+			} else if f, err := v.Float64(); err == nil {
+				values[col] = f
+			} else {
+				return nil, fmt.Errorf("%s.%s: %w", s.Table, col, err)
+			}
+		case string:
+			values[col] = v
+			if isTimeField(field) {
+				if t, ok := parseTime(v); ok {
+					values[col] = t
+				}
+			}
+		default:
+			values[col] = v
+		}
+	}
+
+	return values, nil
+}
+
+// backupTimeLayouts are the timestamp formats converted back to time.Time on import; any
+// other string is stored as is.
+var backupTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999"}
+
+func parseTime(v string) (time.Time, bool) {
+	for _, layout := range backupTimeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func isTimeField(field *schema.Field) bool {
+	t := field.FieldType
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t == reflect.TypeOf(time.Time{}) || t == reflect.TypeOf(gorm.DeletedAt{})
+}
+
+// backupSchemas parses backupModels.
+func backupSchemas() ([]*schema.Schema, error) {
+	schemas := make([]*schema.Schema, len(backupModels))
+	for i, model := range backupModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		schemas[i] = stmt.Schema
+	}
+	return schemas, nil
+}
@@ -3,6 +3,7 @@ package worker
 import (
 	"fmt"
 	"os"
+	"testing"
 
 	"github.com/Hack-Nocturne/cfs3/vars"
 	"github.com/kofj/gorm-driver-d1/gormd1"
@@ -13,6 +14,11 @@ import (
 var db *gorm.DB
 
 func init() {
+	// Test binaries of dependent packages exercise pure logic, they don't get a database.
+	if testing.Testing() {
+		return
+	}
+
 	vars.CF_ACCOUNT_ID = os.Getenv("CF_ACCOUNT_ID")
 	vars.CF_API_TOKEN = os.Getenv("CF_API_TOKEN")
 	cfDBId := os.Getenv("CF_DATABASE_ID")
@@ -32,9 +38,22 @@ func init() {
 	}
 
 	// Migrate the schema
-	migErr := db.AutoMigrate(&Object{})
+	migErr := db.AutoMigrate(&Object{}, &ObjectVersion{}, &Snapshot{}, &SnapshotEntry{}, &Deployment{}, &APIKey{}, &MetadataSchema{}, &MetadataIndex{})
 	if migErr != nil {
 		fmt.Println("❌ Failed to migrate the database schema:", migErr)
 		os.Exit(1)
 	}
+
+	if err := migrateSearch(); err != nil {
+		fmt.Println("❌ Failed to create the search index:", err)
+		os.Exit(1)
+	}
+
+	// Paths used to be unique across all projects; they are now unique per project.
+	if db.Migrator().HasIndex(&Object{}, "idx_objects_rel_path") {
+		if err := db.Migrator().DropIndex(&Object{}, "idx_objects_rel_path"); err != nil {
+			fmt.Println("❌ Failed to drop the legacy rel_path index:", err)
+			os.Exit(1)
+		}
+	}
 }
@@ -0,0 +1,62 @@
+package worker
+
+import "time"
+
+// RecordDeployment stores a completed deployment so the daily budget can be enforced.
+func RecordDeployment(deployment *Deployment) error {
+	return db.Create(deployment).Error
+}
+
+// CountDeploymentsSince returns how many deployments of the project were made after since.
+func CountDeploymentsSince(projName string, since time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&Deployment{}).
+		Where("project_name = ? AND created_at > ?", projName, since).
+		Count(&count).Error
+
+	return count, err
+}
+
+// OldestDeploymentSince returns the earliest deployment of the project made after since, or nil.
+func OldestDeploymentSince(projName string, since time.Time) (*Deployment, error) {
+	var deployments []Deployment
+	err := db.Where("project_name = ? AND created_at > ?", projName, since).
+		Order("created_at ASC").
+		Limit(1).
+		Find(&deployments).Error
+	if err != nil || len(deployments) == 0 {
+		return nil, err
+	}
+
+	return &deployments[0], nil
+}
+
+// SetDeploymentObjects stores the objects a deployment added and removed, together with the
+// newest object version its state includes.
+func SetDeploymentObjects(deploymentId string, added, removed []int64, lastVersionId int64) error {
+	return db.Model(&Deployment{}).
+		Where("id = ?", deploymentId).
+		Select("added_objects", "removed_objects", "last_version_id").
+		Updates(Deployment{AddedObjects: added, RemovedObjects: removed, LastVersionID: lastVersionId}).Error
+}
+
+// FetchDeployment returns a recorded deployment, or nil if it doesn't exist.
+func FetchDeployment(deploymentId string) (*Deployment, error) {
+	var deployments []Deployment
+	if err := db.Where("id = ?", deploymentId).Limit(1).Find(&deployments).Error; err != nil || len(deployments) == 0 {
+		return nil, err
+	}
+
+	return &deployments[0], nil
+}
+
+// ListDeployments returns the most recent deployments of a project, newest first.
+func ListDeployments(projName string, limit int) ([]Deployment, error) {
+	var deployments []Deployment
+	err := db.Where("project_name = ?", projName).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&deployments).Error
+
+	return deployments, err
+}
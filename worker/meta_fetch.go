@@ -3,18 +3,33 @@ package worker
 import (
 	"math/rand"
 	"path/filepath"
+	"slices"
 
 	"github.com/Hack-Nocturne/cfs3/types"
 	"github.com/Hack-Nocturne/cfs3/utils"
+	"gorm.io/gorm"
 )
 
+// maxInParams bounds the values bound in one IN clause, D1 allows at most 100 parameters per query.
+const maxInParams = 90
+
+// insertBatchSize is the number of rows of model that one INSERT can hold without binding
+// more than maxInParams values.
+func insertBatchSize(model any) int {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return 1
+	}
+	return max(1, maxInParams/len(stmt.Schema.DBNames))
+}
+
 func FetchAllMeta(projName string) (map[string]types.FileContainer, error) {
 	var objects []Object
 	if err := db.Where("project_name = ?", projName).Find(&objects).Error; err != nil {
 		return nil, err
 	}
 
-	return createObjectMap(objects), nil
+	return ObjectMap(objects), nil
 }
 
 func FetchAllMetaExcluding(projName string, ids []int64) (map[string]types.FileContainer, error) {
@@ -23,19 +38,23 @@ func FetchAllMetaExcluding(projName string, ids []int64) (map[string]types.FileC
 		return nil, err
 	}
 
-	return createObjectMap(objects), nil
+	return ObjectMap(objects), nil
 }
 
-func createObjectMap(objects []Object) map[string]types.FileContainer {
+// ObjectMap converts objects into the file containers Deploy expects, keyed by path.
+func ObjectMap(objects []Object) map[string]types.FileContainer {
 	objectsMap := make(map[string]types.FileContainer, len(objects))
 
 	for _, obj := range objects {
-		// Cloudflare only cares about the file hash so fake other fields
+		// Cloudflare only cares about the file hash so fake other fields. Path stays empty,
+		// there is no local copy to upload from.
 		fileContainer := types.FileContainer{
 			ContentType: utils.ExtToMimeType(filepath.Ext(obj.RelPath)),
-			Path:        "/home/admin/" + obj.RelPath, // Anything here just works ;)
-			SizeInBytes: rand.Int63n(10485760) + 1024, // Random size between 1KB and 10MB
-			Hash:        obj.Hash,                     // This field is critical ot preserve files that are already deployed
+			SizeInBytes: obj.Size,
+			Hash:        obj.Hash, // This field is critical ot preserve files that are already deployed
+		}
+		if fileContainer.SizeInBytes == 0 {
+			fileContainer.SizeInBytes = rand.Int63n(10485760) + 1024 // Random size between 1KB and 10MB
 		}
 
 		objectsMap[obj.RelPath] = fileContainer
@@ -43,3 +62,47 @@ func createObjectMap(objects []Object) map[string]types.FileContainer {
 
 	return objectsMap
 }
+
+// FetchObjectsByPaths returns the objects of a project stored at the given relative paths.
+func FetchObjectsByPaths(projName string, relPaths []string) ([]Object, error) {
+	var objects []Object
+	if len(relPaths) == 0 {
+		return objects, nil
+	}
+
+	for chunk := range slices.Chunk(relPaths, maxInParams) {
+		var found []Object
+		if err := db.Where("project_name = ? AND rel_path IN ?", projName, chunk).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		objects = append(objects, found...)
+	}
+	return objects, nil
+}
+
+// FetchObjectsByPrefix returns the objects of a project whose path starts with prefix, ordered by path.
+func FetchObjectsByPrefix(projName, prefix string) ([]Object, error) {
+	var objects []Object
+	err := db.Where("project_name = ? AND substr(rel_path, 1, length(?)) = ?", projName, prefix, prefix).
+		Order("rel_path ASC").
+		Find(&objects).Error
+
+	return objects, err
+}
+
+// FetchObjectsByIDs returns the objects of a project with the given IDs.
+func FetchObjectsByIDs(projName string, ids []int64) ([]Object, error) {
+	var objects []Object
+	if len(ids) == 0 {
+		return objects, nil
+	}
+
+	for chunk := range slices.Chunk(ids, maxInParams) {
+		var found []Object
+		if err := db.Where("project_name = ? AND id IN ?", projName, chunk).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		objects = append(objects, found...)
+	}
+	return objects, nil
+}
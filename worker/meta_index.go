@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MetadataIndex records a metadata key promoted to an indexed generated column of objects.
+type MetadataIndex struct {
+	Key       string    `gorm:"primaryKey" json:"key"`
+	Column    string    `json:"column"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromoteMetadataKey adds a virtual generated column holding the metadata key, indexed
+// together with the project, and uses it for predicates on that key from then on.
+// Promoting a key twice is a no-op.
+func PromoteMetadataKey(key string) (*MetadataIndex, error) {
+	path, err := jsonPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []MetadataIndex
+	if err := db.Where("`key` = ?", key).Limit(1).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return &existing[0], nil
+	}
+
+	index := &MetadataIndex{Key: key, Column: metaColumn(key)}
+	var clashes []MetadataIndex
+	if err := db.Where("`column` = ?", index.Column).Limit(1).Find(&clashes).Error; err != nil {
+		return nil, err
+	}
+	if len(clashes) > 0 {
+		return nil, fmt.Errorf("%q maps to the same column as the promoted key %q", key, clashes[0].Key)
+	}
+
+	// The path only holds characters allowed by jsonPath, it can't break out of the literal.
+	// Generated columns can't be added with bound parameters.
+	alter := fmt.Sprintf("ALTER TABLE objects ADD COLUMN %s GENERATED ALWAYS AS (json_extract(metadata, '%s')) VIRTUAL", index.Column, path)
+	if err := db.Exec(alter).Error; err != nil {
+		return nil, err
+	}
+	create := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_objects_%s ON objects (project_name, %s)", index.Column, index.Column)
+	if err := db.Exec(create).Error; err != nil {
+		return nil, err
+	}
+
+	return index, db.Create(index).Error
+}
+
+// DemoteMetadataKey drops the generated column and index of a promoted key. It reports
+// whether the key was promoted.
+func DemoteMetadataKey(key string) (bool, error) {
+	var existing []MetadataIndex
+	if err := db.Where("`key` = ?", key).Limit(1).Find(&existing).Error; err != nil || len(existing) == 0 {
+		return false, err
+	}
+
+	column := existing[0].Column
+	if err := db.Exec("DROP INDEX IF EXISTS idx_objects_" + column).Error; err != nil {
+		return false, err
+	}
+	if err := db.Exec("ALTER TABLE objects DROP COLUMN " + column).Error; err != nil {
+		return false, err
+	}
+
+	return true, db.Delete(&existing[0]).Error
+}
+
+// ListMetadataIndexes returns the promoted metadata keys.
+func ListMetadataIndexes() ([]MetadataIndex, error) {
+	var indexes []MetadataIndex
+	err := db.Order("`key` ASC").Find(&indexes).Error
+	return indexes, err
+}
+
+// promotedKeys maps promoted metadata keys to their columns.
+func promotedKeys() (map[string]string, error) {
+	indexes, err := ListMetadataIndexes()
+	if err != nil {
+		return nil, err
+	}
+
+	promoted := make(map[string]string, len(indexes))
+	for _, index := range indexes {
+		promoted[index.Key] = index.Column
+	}
+	return promoted, nil
+}
+
+// metaColumn derives the generated column name of a metadata key, e.g. "meta_review__status"
+// for "review.status".
+func metaColumn(key string) string {
+	return "meta_" + strings.NewReplacer(".", "__", "-", "_").Replace(strings.ToLower(key))
+}
@@ -0,0 +1,173 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Operators of a MetaPredicate.
+const (
+	MetaEq     = "eq"     // the key equals Value (a string, number, bool or null)
+	MetaExists = "exists" // the key is present, with any value
+	MetaIn     = "in"     // the key equals one of Values (strings or numbers)
+	MetaGt     = "gt"     // the key is a number greater than Value
+	MetaGte    = "gte"
+	MetaLt     = "lt"
+	MetaLte    = "lte"
+)
+
+var numericOps = map[string]string{MetaGt: ">", MetaGte: ">=", MetaLt: "<", MetaLte: "<="}
+
+// MetaPredicate is a condition on the metadata of an object. Key is a dot-separated path into
+// the metadata object, e.g. "review.status".
+type MetaPredicate struct {
+	Key    string `json:"key"`
+	Op     string `json:"op"`
+	Value  any    `json:"value,omitempty"`
+	Values []any  `json:"values,omitempty"`
+}
+
+// Validate checks the key, operator and operands of the predicate.
+func (p MetaPredicate) Validate() error {
+	if _, err := jsonPath(p.Key); err != nil {
+		return err
+	}
+
+	switch p.Op {
+	case MetaEq:
+		switch p.Value.(type) {
+		case nil, string, bool, float64, int, int64:
+		default:
+			return fmt.Errorf("%s: 'eq' compares with a string, number, bool or null", p.Key)
+		}
+	case MetaExists:
+	case MetaIn:
+		if len(p.Values) == 0 {
+			return fmt.Errorf("%s: 'in' requires non-empty values", p.Key)
+		}
+		if len(p.Values) > maxInParams/2 {
+			return fmt.Errorf("%s: 'in' supports at most %d values", p.Key, maxInParams/2)
+		}
+		for _, v := range p.Values {
+			switch v.(type) {
+			case string, float64, int, int64:
+			default:
+				return fmt.Errorf("%s: 'in' values must be strings or numbers", p.Key)
+			}
+		}
+	case MetaGt, MetaGte, MetaLt, MetaLte:
+		switch p.Value.(type) {
+		case float64, int, int64:
+		default:
+			return fmt.Errorf("%s: %q compares with a number", p.Key, p.Op)
+		}
+	default:
+		return fmt.Errorf("%s: unknown operator %q", p.Key, p.Op)
+	}
+
+	return nil
+}
+
+// where adds the predicate to query. A promoted key is read from its generated column, so
+// the column's index can be used.
+func (p MetaPredicate) where(query *gorm.DB, promoted map[string]string) *gorm.DB {
+	path, _ := jsonPath(p.Key)
+	expr, args := "json_extract(metadata, ?)", []any{path}
+	if column, ok := promoted[p.Key]; ok {
+		expr, args = column, nil
+	}
+
+	switch p.Op {
+	case MetaExists:
+		return query.Where("json_type(metadata, ?) IS NOT NULL", path)
+	case MetaEq:
+		switch v := p.Value.(type) {
+		case nil:
+			return query.Where("json_type(metadata, ?) = 'null'", path)
+		case bool:
+			// json_extract turns booleans into 1 and 0, which would also match numbers.
+			return query.Where("json_type(metadata, ?) = ?", path, fmt.Sprint(v))
+		}
+		return query.Where(expr+" = ?", append(args, p.Value)...)
+	case MetaIn:
+		return query.Where(expr+" IN ?", append(args, p.Values)...)
+	default:
+		// Text sorts above every number in SQLite, so only compare numeric values.
+		cond := "typeof(" + expr + ") IN ('integer', 'real') AND " + expr + " " + numericOps[p.Op] + " ?"
+		return query.Where(cond, append(append(args, args...), p.Value)...)
+	}
+}
+
+// params returns the number of parameters the predicate binds at most, when its key isn't promoted.
+func (p MetaPredicate) params() int {
+	switch p.Op {
+	case MetaExists:
+		return 1
+	case MetaEq:
+		return 2
+	case MetaIn:
+		return 1 + len(p.Values)
+	default:
+		return 3
+	}
+}
+
+// CheckMetaQueryParams fails if the query FetchObjectsByMeta makes for predicates could bind
+// more parameters than D1 allows, counting the project and prefix ones.
+func CheckMetaQueryParams(predicates []MetaPredicate) error {
+	n := 3 // project_name and twice the prefix
+	for _, p := range predicates {
+		n += p.params()
+	}
+	if n > maxInParams {
+		return fmt.Errorf("the predicates bind %d query parameters, at most %d are supported", n, maxInParams)
+	}
+	return nil
+}
+
+// FetchObjectsByMeta returns the objects of a project below prefix whose metadata matches
+// every predicate, ordered by path.
+func FetchObjectsByMeta(projName, prefix string, predicates []MetaPredicate) ([]Object, error) {
+	if err := CheckMetaQueryParams(predicates); err != nil {
+		return nil, err
+	}
+	promoted, err := promotedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.Where("project_name = ? AND substr(rel_path, 1, length(?)) = ?", projName, prefix, prefix)
+	for _, p := range predicates {
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+		query = p.where(query, promoted)
+	}
+
+	var objects []Object
+	err = query.Order("rel_path ASC").Find(&objects).Error
+	return objects, err
+}
+
+var metaKeySegment = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// jsonPath turns a dot-separated metadata key into a SQLite JSON path.
+func jsonPath(key string) (string, error) {
+	if key == "" {
+		return "", errors.New("metadata key is required")
+	}
+
+	var b strings.Builder
+	b.WriteString("$")
+	for _, segment := range strings.Split(key, ".") {
+		if !metaKeySegment.MatchString(segment) {
+			return "", fmt.Errorf("invalid metadata key %q: use letters, digits, '_' and '-' separated by '.'", key)
+		}
+		b.WriteString(`."` + segment + `"`)
+	}
+	return b.String(), nil
+}
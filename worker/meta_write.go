@@ -1,17 +1,152 @@
 package worker
 
-import "gorm.io/gorm/clause"
+import (
+	"slices"
 
-func BulkAddObjects(objects []Object) error {
-	err := db.Clauses(clause.OnConflict{DoNothing: true}).
-		CreateInBatches(objects, 50).Error
+	"gorm.io/gorm/clause"
+)
 
-	return err
+// BulkAddObjects inserts objects. An object stored at a path that already exists in the
+// project overwrites the existing row (content, metadata and uploader), taking it out of the
+// trash if it was removed. Whether a path may be overwritten is up to the caller, see the
+// on_conflict policy of patches.
+// The replaced states and the new ones are kept as object versions, whose IDs are returned.
+func BulkAddObjects(objects []Object) ([]int64, error) {
+	if len(objects) == 0 {
+		return nil, nil
+	}
+
+	byProject := make(map[string][]string)
+	for _, obj := range objects {
+		byProject[obj.ProjectName] = append(byProject[obj.ProjectName], obj.RelPath)
+	}
+
+	var versionIds []int64
+	for projName, relPaths := range byProject {
+		previous, err := FetchObjectsByPaths(projName, relPaths)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := recordVersions(previous, VersionReplaced)
+		if err != nil {
+			return nil, err
+		}
+		versionIds = append(versionIds, ids...)
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "project_name"}, {Name: "rel_path"}},
+		DoUpdates: clause.AssignmentColumns([]string{"hash", "name", "size", "metadata", "added_by", "updated_at", "deleted_at"}),
+	}).CreateInBatches(objects, insertBatchSize(&Object{})).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the rows back, IDs of updated rows aren't reported by the upsert.
+	for projName, relPaths := range byProject {
+		current, err := FetchObjectsByPaths(projName, relPaths)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := recordVersions(current, VersionAdded)
+		if err != nil {
+			return nil, err
+		}
+		versionIds = append(versionIds, ids...)
+		if err := indexObjects(current); err != nil {
+			return nil, err
+		}
+	}
+
+	return versionIds, nil
+}
+
+// BulkRemoveObjects moves objects to the trash, see PurgeTrashedObjects. It returns the IDs
+// of the versions it wrote.
+func BulkRemoveObjects(ids []int64) ([]int64, error) {
+	var removed []Object
+	for chunk := range slices.Chunk(ids, maxInParams) {
+		var found []Object
+		if err := db.Where("id IN ?", chunk).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		removed = append(removed, found...)
+	}
+	versionIds, err := recordVersions(removed, VersionRemoved)
+	if err != nil {
+		return nil, err
+	}
+
+	for chunk := range slices.Chunk(ids, maxInParams) {
+		err := db.Clauses(clause.OnConflict{DoNothing: true}).
+			Delete(&Object{}, "id IN ?", chunk).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Only once they are gone, a failed delete must leave the objects searchable.
+	return versionIds, unindexObjects(ids)
 }
 
-func BulkRemoveObjects(ids []int64) error {
-	err := db.Clauses(clause.OnConflict{DoNothing: true}).
-		Delete(&Object{}, "id IN ?", ids).Error
+// UpdateObjectsMetadata writes the metadata of the given objects of a project. The previous
+// and new states are kept as object versions, whose IDs are returned.
+func UpdateObjectsMetadata(projName string, objects []Object) ([]int64, error) {
+	if len(objects) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, len(objects))
+	for i, obj := range objects {
+		ids[i] = obj.ID
+	}
+
+	previous, err := FetchObjectsByIDs(projName, ids)
+	if err != nil {
+		return nil, err
+	}
+	versionIds, err := recordVersions(previous, VersionReplaced)
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects that end up with the same metadata are written together.
+	byMetadata := make(map[string][]int64)
+	var cleared []int64 // objects whose metadata becomes NULL
+	for _, obj := range objects {
+		if obj.Metadata == nil {
+			cleared = append(cleared, obj.ID)
+		} else {
+			byMetadata[*obj.Metadata] = append(byMetadata[*obj.Metadata], obj.ID)
+		}
+	}
+	update := func(metadata *string, ids []int64) error {
+		for chunk := range slices.Chunk(ids, maxInParams) {
+			err := db.Model(&Object{}).
+				Where("project_name = ? AND id IN ?", projName, chunk).
+				Update("metadata", metadata).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for metadata, ids := range byMetadata {
+		if err := update(&metadata, ids); err != nil {
+			return nil, err
+		}
+	}
+	if err := update(nil, cleared); err != nil {
+		return nil, err
+	}
 
-	return err
+	current, err := FetchObjectsByIDs(projName, ids)
+	if err != nil {
+		return nil, err
+	}
+	added, err := recordVersions(current, VersionAdded)
+	if err != nil {
+		return nil, err
+	}
+	return append(versionIds, added...), indexObjects(current)
 }
@@ -1,13 +1,100 @@
 package worker
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 var GlobalObjects []Object
 
 type Object struct {
-	ID          int64 `gorm:"primaryKey"`
-	Hash        string
-	RelPath     string `gorm:"uniqueIndex"`
-	Name        string
-	AddedBy     *string
+	ID          int64     `gorm:"primaryKey" json:"id"`
+	Hash        string    `json:"hash"`
+	RelPath     string    `gorm:"uniqueIndex:idx_objects_project_rel_path,priority:2" json:"rel_path"`
+	Name        string    `json:"name"`
+	AddedBy     *string   `json:"added_by"`
+	ProjectName string    `gorm:"index;uniqueIndex:idx_objects_project_rel_path,priority:1" json:"project_name"`
+	Metadata    *string   `json:"metadata"`
+	Size        int64     `json:"size"` // 0 for objects recorded before sizes were tracked
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// DeletedAt marks removed objects that are kept in the trash. The row still holds its
+	// path, so adding a file at that path again revives it.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// ObjectVersion is a past or present state of an object. A version is written for every
+// object added, for the state an add replaces and for every object removed.
+type ObjectVersion struct {
+	ID          int64     `gorm:"primaryKey" json:"id"`
+	ObjectID    int64     `gorm:"index" json:"object_id"`
+	ProjectName string    `gorm:"index:idx_object_versions_project_rel_path,priority:1" json:"project_name"`
+	RelPath     string    `gorm:"index:idx_object_versions_project_rel_path,priority:2" json:"rel_path"`
+	Hash        string    `json:"hash"`
+	Name        string    `json:"name"`
+	AddedBy     *string   `json:"added_by"`
+	Metadata    *string   `json:"metadata"`
+	Size        int64     `json:"size"`
+	Action      string    `json:"action"` // VersionAdded, VersionReplaced or VersionRemoved
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Snapshot is a named copy of a project's object set, see SnapshotEntry.
+type Snapshot struct {
+	ID            int64     `gorm:"primaryKey" json:"id"`
+	ProjectName   string    `gorm:"uniqueIndex:idx_snapshots_project_name,priority:1" json:"project_name"`
+	Name          string    `gorm:"uniqueIndex:idx_snapshots_project_name,priority:2" json:"name"`
+	SourceProject string    `json:"source_project,omitempty"` // project the snapshot was taken of, when imported from another one
+	By            *string   `json:"by"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SnapshotEntry is one object of a snapshot.
+type SnapshotEntry struct {
+	ID         int64   `gorm:"primaryKey" json:"-"`
+	SnapshotID int64   `gorm:"index" json:"-"`
+	RelPath    string  `json:"rel_path"`
+	Hash       string  `json:"hash"`
+	Name       string  `json:"name"`
+	AddedBy    *string `json:"added_by"`
+	Metadata   *string `json:"metadata"`
+	Size       int64   `json:"size"`
+}
+
+// Deployment records a Pages deployment made by cfs3.
+type Deployment struct {
+	ID          string `gorm:"primaryKey"` // Cloudflare deployment ID
 	ProjectName string `gorm:"index"`
-	Metadata    *string
+	URL         string
+	Mode        string
+	By          *string
+	CreatedAt   time.Time `gorm:"index"`
+
+	AddedObjects   []int64 `gorm:"serializer:json"` // IDs of the objects added or replaced
+	RemovedObjects []int64 `gorm:"serializer:json"` // IDs of the objects removed
+	// LastVersionID is the newest object version the deployment's D1 changes wrote, 0 for
+	// deployments recorded before it was tracked. It pins the project state.
+	LastVersionID int64
+}
+
+// MetadataSchema is the JSON Schema the metadata of a project's objects must follow.
+type MetadataSchema struct {
+	ProjectName string    `gorm:"primaryKey" json:"project_name"`
+	Schema      string    `json:"schema"`
+	By          *string   `json:"by"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// APIKey is a cfs3-issued key for the management API. Only a SHA-256 digest of the key is stored.
+type APIKey struct {
+	ID         int64      `gorm:"primaryKey" json:"id"`
+	Owner      string     `gorm:"index" json:"owner"`
+	Prefix     string     `json:"prefix"` // first characters of the key, to tell keys apart
+	KeyHash    string     `gorm:"uniqueIndex" json:"-"`
+	Project    string     `json:"project"` // the only project the key may access, empty for every project
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
 }
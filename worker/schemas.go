@@ -0,0 +1,27 @@
+package worker
+
+import "gorm.io/gorm/clause"
+
+// SetMetadataSchema registers or replaces the metadata schema of a project.
+func SetMetadataSchema(schema *MetadataSchema) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "project_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"schema", "by", "updated_at"}),
+	}).Create(schema).Error
+}
+
+// FetchMetadataSchema returns the metadata schema of a project, or nil if none is registered.
+func FetchMetadataSchema(projName string) (*MetadataSchema, error) {
+	var schemas []MetadataSchema
+	err := db.Where("project_name = ?", projName).Limit(1).Find(&schemas).Error
+	if err != nil || len(schemas) == 0 {
+		return nil, err
+	}
+	return &schemas[0], nil
+}
+
+// DeleteMetadataSchema removes the metadata schema of a project. It reports whether one was registered.
+func DeleteMetadataSchema(projName string) (bool, error) {
+	result := db.Where("project_name = ?", projName).Delete(&MetadataSchema{})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// searchTable is the FTS5 index over the live objects. Its rowid is the object ID.
+const searchTable = "objects_search"
+
+// searchWeights ranks matches in the name above the path, and the path above metadata.
+const searchWeights = "0.0, 10.0, 5.0, 1.0" // project_name, name, rel_path, metadata
+
+// searchRow is a row of searchTable.
+type searchRow struct {
+	ObjectID    int64  `gorm:"column:rowid"`
+	ProjectName string `gorm:"column:project_name"`
+	Name        string `gorm:"column:name"`
+	RelPath     string `gorm:"column:rel_path"`
+	Metadata    string `gorm:"column:metadata"`
+}
+
+// SearchResult is an object matching a search, with its bm25 rank (lower is better).
+type SearchResult struct {
+	Object
+	Rank float64 `json:"rank"`
+}
+
+// migrateSearch creates the search index, filling it on creation.
+func migrateSearch() error {
+	exists := db.Migrator().HasTable(searchTable)
+	err := db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS " + searchTable +
+		" USING fts5(project_name UNINDEXED, name, rel_path, metadata)").Error
+	if err != nil || exists {
+		return err
+	}
+
+	return RebuildSearchIndex()
+}
+
+// RebuildSearchIndex drops the search index entries and indexes every live object again.
+func RebuildSearchIndex() error {
+	if err := db.Exec("DELETE FROM " + searchTable).Error; err != nil {
+		return err
+	}
+
+	var lastId int64
+	for {
+		var objects []Object
+		if err := db.Where("id > ?", lastId).Order("id").Limit(500).Find(&objects).Error; err != nil {
+			return err
+		}
+		if err := indexObjects(objects); err != nil {
+			return err
+		}
+		if len(objects) < 500 {
+			return nil
+		}
+		lastId = objects[len(objects)-1].ID
+	}
+}
+
+// indexObjects adds objects to the search index, replacing their previous entries.
+func indexObjects(objects []Object) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(objects))
+	rows := make([]searchRow, len(objects))
+	for i, obj := range objects {
+		ids[i] = obj.ID
+		rows[i] = searchRow{
+			ObjectID:    obj.ID,
+			ProjectName: obj.ProjectName,
+			Name:        obj.Name,
+			RelPath:     obj.RelPath,
+			Metadata:    metadataText(obj.Metadata),
+		}
+	}
+
+	if err := unindexObjects(ids); err != nil {
+		return err
+	}
+	return db.Table(searchTable).CreateInBatches(rows, maxInParams/5).Error
+}
+
+// unindexObjects removes objects from the search index.
+func unindexObjects(ids []int64) error {
+	for chunk := range slices.Chunk(ids, maxInParams) {
+		if err := db.Exec("DELETE FROM "+searchTable+" WHERE rowid IN ?", chunk).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchObjects returns the objects of a project whose name, path or metadata string values
+// contain every term of query, best matches first. Terms match word prefixes.
+func SearchObjects(projName, query string, limit int) ([]SearchResult, error) {
+	match := searchQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	var hits []struct {
+		ObjectID int64
+		Score    float64
+	}
+	err := db.Raw("SELECT rowid AS object_id, bm25("+searchTable+", "+searchWeights+") AS score FROM "+searchTable+
+		" WHERE "+searchTable+" MATCH ? AND project_name = ? ORDER BY score LIMIT ?", match, projName, limit).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ObjectID
+	}
+	objects, err := FetchObjectsByIDs(projName, ids)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[int64]Object, len(objects))
+	for _, obj := range objects {
+		byId[obj.ID] = obj
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		if obj, ok := byId[hit.ObjectID]; ok {
+			results = append(results, SearchResult{Object: obj, Rank: hit.Score})
+		}
+	}
+	return results, nil
+}
+
+// searchQuery turns free text into an FTS5 query of quoted prefix terms, so punctuation in
+// the input can't be taken for query syntax.
+func searchQuery(query string) string {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		terms = append(terms, `"`+strings.ReplaceAll(term, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// metadataText collects the string values of metadata, at any depth, for the search index.
+func metadataText(metadata *string) string {
+	if metadata == nil {
+		return ""
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(*metadata), &value); err != nil {
+		return ""
+	}
+
+	var texts []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case string:
+			texts = append(texts, v)
+		case []any:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]any:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(value)
+
+	slices.Sort(texts) // map order is random, keep the indexed text stable
+	return strings.Join(texts, " ")
+}
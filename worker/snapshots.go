@@ -0,0 +1,58 @@
+package worker
+
+// CreateSnapshot stores a snapshot and its entries.
+func CreateSnapshot(snapshot *Snapshot, entries []SnapshotEntry) error {
+	if err := db.Create(snapshot).Error; err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	for i := range entries {
+		entries[i].SnapshotID = snapshot.ID
+	}
+	if err := db.CreateInBatches(entries, insertBatchSize(&SnapshotEntry{})).Error; err != nil {
+		// Don't leave a snapshot behind that silently lacks objects.
+		db.Delete(snapshot)
+		return err
+	}
+
+	return nil
+}
+
+// FetchSnapshot returns the named snapshot of a project with its entries, or nil if it doesn't exist.
+func FetchSnapshot(projName, name string) (*Snapshot, []SnapshotEntry, error) {
+	var snapshots []Snapshot
+	err := db.Where("project_name = ? AND name = ?", projName, name).Limit(1).Find(&snapshots).Error
+	if err != nil || len(snapshots) == 0 {
+		return nil, nil, err
+	}
+
+	var entries []SnapshotEntry
+	if err := db.Where("snapshot_id = ?", snapshots[0].ID).Order("rel_path ASC").Find(&entries).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return &snapshots[0], entries, nil
+}
+
+// ListSnapshots returns the snapshots of a project, newest first.
+func ListSnapshots(projName string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	err := db.Where("project_name = ?", projName).Order("created_at DESC").Find(&snapshots).Error
+	return snapshots, err
+}
+
+// DeleteSnapshot deletes a snapshot and its entries. It reports whether the snapshot existed.
+func DeleteSnapshot(projName, name string) (bool, error) {
+	snapshot, _, err := FetchSnapshot(projName, name)
+	if err != nil || snapshot == nil {
+		return false, err
+	}
+
+	if err := db.Where("snapshot_id = ?", snapshot.ID).Delete(&SnapshotEntry{}).Error; err != nil {
+		return false, err
+	}
+	return true, db.Delete(snapshot).Error
+}
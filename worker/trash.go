@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"slices"
+	"time"
+)
+
+// ListTrashedObjects returns the removed objects of a project that are still in the trash,
+// most recently removed first.
+func ListTrashedObjects(projName string) ([]Object, error) {
+	var objects []Object
+	err := db.Unscoped().
+		Where("project_name = ? AND deleted_at IS NOT NULL", projName).
+		Order("deleted_at DESC").
+		Find(&objects).Error
+
+	return objects, err
+}
+
+// FetchTrashedObjects returns the trashed objects of a project with the given IDs.
+func FetchTrashedObjects(projName string, ids []int64) ([]Object, error) {
+	var objects []Object
+	for chunk := range slices.Chunk(ids, maxInParams) {
+		var found []Object
+		err := db.Unscoped().
+			Where("project_name = ? AND deleted_at IS NOT NULL AND id IN ?", projName, chunk).
+			Find(&found).Error
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, found...)
+	}
+
+	return objects, nil
+}
+
+// UndeleteObjects takes objects out of the trash and records them as added again. It returns
+// the IDs of the versions it wrote.
+func UndeleteObjects(projName string, ids []int64) ([]int64, error) {
+	for chunk := range slices.Chunk(ids, maxInParams) {
+		err := db.Unscoped().Model(&Object{}).
+			Where("project_name = ? AND id IN ?", projName, chunk).
+			Update("deleted_at", nil).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	objects, err := FetchObjectsByIDs(projName, ids)
+	if err != nil {
+		return nil, err
+	}
+	versionIds, err := recordVersions(objects, VersionAdded)
+	if err != nil {
+		return nil, err
+	}
+	return versionIds, indexObjects(objects)
+}
+
+// PurgeTrashedObjects permanently deletes the objects of a project removed before the given
+// time and returns how many were deleted. Their versions are kept.
+func PurgeTrashedObjects(projName string, before time.Time) (int64, error) {
+	result := db.Unscoped().
+		Where("project_name = ? AND deleted_at IS NOT NULL AND deleted_at < ?", projName, before).
+		Delete(&Object{})
+
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,75 @@
+package worker
+
+// Actions recorded on object versions.
+const (
+	VersionAdded    = "added"    // the state written by an add
+	VersionReplaced = "replaced" // the state an add overwrote
+	VersionRemoved  = "removed"  // the state of a removed object
+)
+
+// recordVersions stores the current state of the given objects as versions and returns
+// their IDs.
+func recordVersions(objects []Object, action string) ([]int64, error) {
+	if len(objects) == 0 {
+		return nil, nil
+	}
+
+	versions := make([]ObjectVersion, len(objects))
+	for i, obj := range objects {
+		versions[i] = ObjectVersion{
+			ObjectID:    obj.ID,
+			ProjectName: obj.ProjectName,
+			RelPath:     obj.RelPath,
+			Hash:        obj.Hash,
+			Name:        obj.Name,
+			AddedBy:     obj.AddedBy,
+			Metadata:    obj.Metadata,
+			Size:        obj.Size,
+			Action:      action,
+		}
+	}
+
+	if err := db.CreateInBatches(versions, insertBatchSize(&ObjectVersion{})).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(versions))
+	for i, v := range versions {
+		ids[i] = v.ID
+	}
+	return ids, nil
+}
+
+// LastVersionID returns the ID of the newest object version of a project, 0 if it has none.
+func LastVersionID(projName string) (int64, error) {
+	var lastVersionId int64
+	err := db.Model(&ObjectVersion{}).
+		Where("project_name = ?", projName).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&lastVersionId).Error
+
+	return lastVersionId, err
+}
+
+// ListObjectVersions returns the versions of a project, newest first. An empty relPath lists
+// the versions of every path.
+func ListObjectVersions(projName, relPath string) ([]ObjectVersion, error) {
+	query := db.Where("project_name = ?", projName)
+	if relPath != "" {
+		query = query.Where("rel_path = ?", relPath)
+	}
+
+	var versions []ObjectVersion
+	err := query.Order("id DESC").Find(&versions).Error
+	return versions, err
+}
+
+// FetchObjectVersion returns a single version, or nil if it doesn't exist.
+func FetchObjectVersion(id int64) (*ObjectVersion, error) {
+	var versions []ObjectVersion
+	if err := db.Where("id = ?", id).Limit(1).Find(&versions).Error; err != nil || len(versions) == 0 {
+		return nil, err
+	}
+
+	return &versions[0], nil
+}